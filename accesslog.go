@@ -0,0 +1,40 @@
+//go:build go1.21
+
+package trout
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLog returns middleware that logs each request to `l` once it has been
+// served, with the request's method, the pattern of the Endpoint or Prefix it
+// matched, the status code of the response, and how long it took to serve.
+// Logging the pattern instead of the request path keeps the number of
+// distinct values low, which makes the logs easier to aggregate.
+//
+// AccessLog should be used as Router middleware, set using
+// Router.SetMiddleware, so that it runs after the request has been routed and
+// can see the pattern it matched. Requests that didn't match an Endpoint or
+// Prefix are logged with an empty pattern. Requests given an ID by RequestID
+// have it logged too.
+func AccessLog(l *slog.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := newStatusResponseWriter(w)
+			h.ServeHTTP(sw, r)
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("pattern", Pattern(r)),
+				slog.Int("status", sw.Status()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if id := RequestIDFromRequest(r); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			l.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+		})
+	}
+}
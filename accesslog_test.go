@@ -0,0 +1,76 @@
+//go:build go1.21
+
+package trout
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	type testCase struct {
+		method, url, pattern string
+		status               int
+	}
+	cases := []testCase{
+		{"GET", "/posts/foo", "/posts/{slug}", http.StatusTeapot},
+		{"POST", "/posts/foo", "/posts/{slug}", http.StatusMethodNotAllowed},
+		{"GET", "/static/site.css", "/static::prefix", http.StatusOK},
+		{"GET", "/missing", "", http.StatusNotFound},
+	}
+	var buf bytes.Buffer
+	var router Router
+	router.SetMiddleware(AccessLog(slog.New(slog.NewJSONHandler(&buf, nil))))
+	router.Endpoint("/posts/{slug}").GET(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	router.Prefix("/static").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	})
+	for _, c := range cases {
+		buf.Reset()
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		var entry struct {
+			Method  string
+			Pattern string
+			Status  int
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("Error parsing log entry %q: %+v", buf.String(), err)
+		}
+		if entry.Method != c.method || entry.Pattern != c.pattern || entry.Status != c.status {
+			t.Errorf("Expected \"%s %s\" to log %s %q %d, logged %s", c.method, c.url, c.method, c.pattern, c.status, buf.String())
+		}
+	}
+}
+
+func TestAccessLogRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	var router Router
+	router.SetPreMiddleware(RequestID(""))
+	router.SetMiddleware(AccessLog(slog.New(slog.NewJSONHandler(&buf, nil))))
+	router.Endpoint("/").Handler(testHandler("root"))
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	r.Header.Set(DefaultRequestIDHeader, "abc-123")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	var entry struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Error parsing log entry %q: %+v", buf.String(), err)
+	}
+	if entry.RequestID != "abc-123" {
+		t.Errorf("Expected the request ID to be logged, logged %s", buf.String())
+	}
+}
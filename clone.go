@@ -0,0 +1,17 @@
+package trout
+
+// Clone returns a copy of `router` with its own configuration, so its
+// properties, prefix, and middleware can be changed without affecting
+// `router`. This makes it possible to layer things like tenant-specific
+// middleware or 404 handlers over a shared set of routes.
+//
+// The Endpoints and Prefixes are shared with `router`, not copied: they
+// must not be changed or added to on either Router once it has been cloned,
+// or both Routers will see the changes. Swap can still be used on either
+// Router, and will replace the routes for both.
+func (router *Router) Clone() *Router {
+	clone := *router
+	clone.middleware = appendMiddleware(nil, router.middleware)
+	clone.preMiddleware = appendMiddleware(nil, router.preMiddleware)
+	return &clone
+}
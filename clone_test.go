@@ -0,0 +1,50 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Middleware", name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	base := NewRouter(WithMiddleware(mw("base")))
+	base.Endpoint("/posts").Handler(testHandler("posts"))
+
+	tenant := base.Clone()
+	tenant.AddMiddleware(mw("tenant"))
+	tenant.Handle404 = testHandler("tenant-404")
+
+	type testCase struct {
+		router     *Router
+		url, body  string
+		middleware []string
+	}
+	cases := []testCase{
+		{base, "/posts", "posts", []string{"base"}},
+		{tenant, "/posts", "posts", []string{"base", "tenant"}},
+		{base, "/missing", "404 Page Not Found", []string{"base"}},
+		{tenant, "/missing", "tenant-404", []string{"base", "tenant"}},
+	}
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		c.router.ServeHTTP(w, r)
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected %s to have a body of %q, got %q", c.url, c.body, body)
+		}
+		if middleware := w.Header()["Middleware"]; len(middleware) != len(c.middleware) {
+			t.Errorf("Expected middleware %v for %s, got %v", c.middleware, c.url, middleware)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package trout
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// contextKey is the type used for keys trout stores in request contexts, to
+// avoid collisions with keys from other packages.
+type contextKey struct {
+	name string
+}
+
+// routeContextKey is the key the matched route is stored under in the
+// request context.
+var routeContextKey = &contextKey{"route"}
+
+// withRoute returns a shallow copy of `r` with `rt` stored in its context.
+func withRoute(r *http.Request, rt *route) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeContextKey, rt))
+}
+
+// routeFromRequest returns the route stored in the context of `r`, or nil if
+// `r` wasn't routed by a Router.
+func routeFromRequest(r *http.Request) *route {
+	rt, _ := r.Context().Value(routeContextKey).(*route)
+	return rt
+}
+
+// methodsFromRequest returns the methods the route that matched `r` has
+// handlers for, falling back on the Trout-Methods header if `r` wasn't
+// routed by a Router.
+func methodsFromRequest(r *http.Request) []string {
+	if rt := routeFromRequest(r); rt != nil {
+		return rt.methods
+	}
+	return r.Header[http.CanonicalHeaderKey("Trout-Methods")]
+}
+
+// stripTroutHeaders removes any headers from `r` that trout would set, so
+// clients can't spoof them.
+func stripTroutHeaders(r *http.Request) {
+	for h := range r.Header {
+		if strings.HasPrefix(h, "Trout-") {
+			delete(r.Header, h)
+		}
+	}
+}
+
+// Pattern returns the URL template of the Endpoint or Prefix that matched
+// `r`, as it would be set in the Trout-Pattern header. It returns an empty
+// string if no Endpoint or Prefix matched, or `r` wasn't routed by a Router.
+//
+// The pattern has a low cardinality compared to the request path, which
+// makes it useful for labelling logs and metrics.
+func Pattern(r *http.Request) string {
+	if rt := routeFromRequest(r); rt != nil {
+		return rt.pattern
+	}
+	return ""
+}
+
+// RemainderPathValue is the name the remainder of a request matched by a
+// Prefix is stored under as a path value when building with Go 1.22 or later,
+// so `r.PathValue(trout.RemainderPathValue)` returns the same thing as
+// PrefixRemainder. It can't be used as the name of a parameter.
+const RemainderPathValue = "..."
+
+// PathValue returns the value of the parameter `name` of the Endpoint or
+// Prefix that matched `r`, like http.Request.PathValue does when building
+// with Go 1.22 or later, but it works with every version of Go. The name is
+// matched exactly as it was written in the template, and if it was used more
+// than once, the last value is returned, just like http.Request.PathValue.
+// RemainderPathValue can be used to get the remainder of a request matched
+// by a Prefix.
+//
+// For requests that weren't routed by a Router, PathValue returns whatever
+// http.Request.PathValue does when building with Go 1.22 or later, and an
+// empty string otherwise.
+func PathValue(r *http.Request, name string) string {
+	rt := routeFromRequest(r)
+	if rt == nil {
+		return builtinRequestPathVar(r, name)
+	}
+	if name == RemainderPathValue && rt.prefix {
+		return rt.remainder
+	}
+	for i := len(rt.orderedParams) - 1; i >= 0; i-- {
+		if rt.orderedParams[i].Name == name {
+			return rt.orderedParams[i].Value
+		}
+	}
+	return ""
+}
+
+// PrefixRemainder returns the part of the path of `r` after the Prefix that
+// matched it, without a leading `/`. For example, if the Prefix
+// `/files/{id}` matched a request for `/files/foo/a/b/c`, PrefixRemainder
+// returns `a/b/c`. Like parameters, the remainder is unescaped. It returns an
+// empty string if the request matched the Prefix exactly, if it matched an
+// Endpoint instead of a Prefix, or if `r` wasn't routed by a Router.
+func PrefixRemainder(r *http.Request) string {
+	if rt := routeFromRequest(r); rt != nil {
+		return rt.remainder
+	}
+	return ""
+}
+
+// IsPrefixMatch returns true if `r` was routed to a Prefix, and false if it
+// was routed to an Endpoint, matched nothing, or wasn't routed by a Router.
+// Handlers shared between Endpoints and Prefixes can use it to decide
+// whether to look at PrefixRemainder.
+func IsPrefixMatch(r *http.Request) bool {
+	if rt := routeFromRequest(r); rt != nil {
+		return rt.prefix
+	}
+	return false
+}
+
+// Elapsed returns how long it took the Router to route `r`, as it would be
+// set in the Trout-Timer header. It returns 0 if `r` wasn't routed by a
+// Router, or the Router's Timing property wasn't set to true.
+func Elapsed(r *http.Request) time.Duration {
+	if rt := routeFromRequest(r); rt != nil {
+		return rt.elapsed
+	}
+	return 0
+}
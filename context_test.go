@@ -0,0 +1,168 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPatternAndElapsed(t *testing.T) {
+	type testCase struct {
+		url, pattern string
+	}
+	cases := []testCase{
+		{"/posts/foo", "/posts/{slug}"},
+		{"/missing", ""},
+	}
+	var router Router
+	router.Timing = true
+	var pattern string
+	var elapsed bool
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern = Pattern(r)
+		elapsed = Elapsed(r) > 0
+	})
+	router.Handle404 = record
+	router.Endpoint("/posts/{slug}").Handler(record)
+	for _, c := range cases {
+		pattern, elapsed = "unset", false
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if pattern != c.pattern {
+			t.Errorf("Expected %s to have pattern %q, got %q", c.url, c.pattern, pattern)
+		}
+		if !elapsed {
+			t.Errorf("Expected %s to have a non-zero elapsed time", c.url)
+		}
+	}
+
+	r, err := http.NewRequest("GET", "/posts/foo", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	if p := Pattern(r); p != "" {
+		t.Errorf("Expected an unrouted request to have no pattern, got %q", p)
+	}
+	if e := Elapsed(r); e != 0 {
+		t.Errorf("Expected an unrouted request to have no elapsed time, got %s", e)
+	}
+
+	router.Timing = false
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if elapsed {
+		t.Error("Expected no elapsed time without Timing set")
+	}
+	if h := r.Header.Get("Trout-Timer"); h != "" {
+		t.Errorf("Expected no Trout-Timer header without Timing set, got %q", h)
+	}
+}
+
+func TestPrefixRemainder(t *testing.T) {
+	type testCase struct {
+		url, remainder string
+	}
+	cases := []testCase{
+		{"/files/foo/a/b/c", "a/b/c"},
+		{"/files/foo/a/b/c/", "a/b/c"},
+		{"/files/foo/a%2Fb/c%20d", "a/b/c d"},
+		{"/files/foo", ""},
+		{"/files/foo/", ""},
+		{"/posts/foo", ""},
+		{"/missing/foo", ""},
+	}
+	var router Router
+	var remainder string
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remainder = PrefixRemainder(r)
+	})
+	router.Handle404 = record
+	router.Prefix("/files/{id}").Handler(record)
+	router.Endpoint("/posts/{slug}").Handler(record)
+	for _, c := range cases {
+		remainder = "unset"
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if remainder != c.remainder {
+			t.Errorf("Expected %s to have remainder %q, got %q", c.url, c.remainder, remainder)
+		}
+	}
+}
+
+func TestPathValue(t *testing.T) {
+	type testCase struct {
+		url, name, value string
+	}
+	cases := []testCase{
+		{"/posts/Hello/comments/1", "slug", "Hello"},
+		{"/posts/Hello/comments/1", "Slug", ""},
+		{"/posts/Hello/comments/1", "id", "1"},
+		{"/posts/Hello/comments/1", RemainderPathValue, ""},
+		{"/pairs/a/b", "v", "b"},
+		{"/files/foo/a/b", "id", "foo"},
+		{"/files/foo/a/b", RemainderPathValue, "a/b"},
+		{"/missing", "id", ""},
+	}
+	var router Router
+	var name, value string
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value = PathValue(r, name)
+	})
+	router.Handle404 = record
+	router.Endpoint("/posts/{slug}/comments/{id}").Handler(record)
+	router.Endpoint("/pairs/{v}/{v}").Handler(record)
+	router.Prefix("/files/{id}").Handler(record)
+	for _, c := range cases {
+		name, value = c.name, "unset"
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if value != c.value {
+			t.Errorf("Expected %s to have %q set to %q, got %q", c.url, c.name, c.value, value)
+		}
+	}
+}
+
+func TestIsPrefixMatch(t *testing.T) {
+	type testCase struct {
+		url    string
+		prefix bool
+	}
+	cases := []testCase{
+		{"/files/foo/a/b", true},
+		{"/files/foo", true},
+		{"/files/foo/bar", false},
+		{"/posts/foo", false},
+		{"/missing", false},
+	}
+	var router Router
+	var prefix bool
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix = IsPrefixMatch(r)
+	})
+	router.Handle404 = record
+	router.Prefix("/files/{id}").Handler(record)
+	router.Endpoint("/files/{id}/bar").Handler(record)
+	router.Endpoint("/posts/{slug}").Handler(record)
+	for _, c := range cases {
+		prefix = !c.prefix
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if prefix != c.prefix {
+			t.Errorf("Expected IsPrefixMatch for %s to be %v, got %v", c.url, c.prefix, prefix)
+		}
+	}
+	if r, _ := http.NewRequest("GET", "/files/foo", nil); IsPrefixMatch(r) {
+		t.Errorf("Expected a request that wasn't routed not to be a prefix match")
+	}
+}
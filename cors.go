@@ -0,0 +1,147 @@
+package trout
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures the middleware returned by CORS.
+type CORSOptions struct {
+	// AllowedOrigins are the origins allowed to make cross-origin
+	// requests, like `https://example.com`. Origins are compared
+	// case-insensitively. An origin of `*` allows any origin, and can't
+	// be used with AllowCredentials.
+	AllowedOrigins []string
+
+	// AllowedHeaders are the request headers cross-origin requests may
+	// use, sent in response to preflight requests. If empty, the headers
+	// the preflight request asked for are allowed.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers cross-origin requests may
+	// read, other than the ones that are always readable.
+	ExposedHeaders []string
+
+	// AllowCredentials, when set to true, will allow cross-origin
+	// requests to include credentials like cookies. Allowing them from
+	// any origin would let every site on the web make authenticated
+	// requests using a visitor's cookies, so CORS panics if
+	// AllowedOrigins includes `*` and AllowCredentials is set.
+	AllowCredentials bool
+
+	// MaxAge is how long the response to a preflight request may be
+	// cached for. If 0, no Access-Control-Max-Age header is sent.
+	MaxAge time.Duration
+}
+
+// allowsOrigin returns true if `origin` is one of `opts.AllowedOrigins`,
+// along with whether it's only allowed because any origin is.
+func (opts CORSOptions) allowsOrigin(origin string) (allowed, anyOrigin bool) {
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			anyOrigin = true
+			allowed = true
+			continue
+		}
+		if strings.EqualFold(o, origin) {
+			return true, false
+		}
+	}
+	return allowed, anyOrigin
+}
+
+// CORS returns middleware that responds to cross-origin requests according
+// to `opts`. Preflight requests, OPTIONS requests with an
+// Access-Control-Request-Method header, are answered with a 204, with the
+// Allow and Access-Control-Allow-Methods headers listing the methods the
+// matched Endpoint or Prefix has handlers for, so the methods never drift
+// from the Router's configuration. Other cross-origin requests from allowed
+// origins have the CORS response headers set, and are then passed on.
+//
+// Requests without an Origin header, from origins that aren't allowed, and
+// preflight requests for Endpoints or Prefixes that don't have handlers set
+// using the Methods method are passed on without any CORS headers. Every
+// response that doesn't allow any origin gets a `Vary: Origin` header, so
+// caches don't serve it to other origins.
+//
+// CORS should be used as Router middleware, set using Router.SetMiddleware,
+// so that it runs after the request has been routed and can see the methods
+// of the Endpoint or Prefix it matched. CORS panics if `opts` allows
+// credentials from any origin.
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	if opts.AllowCredentials {
+		for _, o := range opts.AllowedOrigins {
+			if o == "*" {
+				panic(errors.New("trout: CORS can't allow credentials from any origin"))
+			}
+		}
+	}
+	allowedHeaders := strings.Join(opts.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(opts.ExposedHeaders, ", ")
+	var maxAge string
+	if opts.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(opts.MaxAge/time.Second), 10)
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// only a response allowing any origin is the same no
+			// matter what the Origin header says, so every other
+			// response varies by it, including the ones without any
+			// CORS headers, or a shared cache could serve them to
+			// origins that should get CORS headers
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				w.Header().Add("Vary", "Origin")
+				h.ServeHTTP(w, r)
+				return
+			}
+			allowed, anyOrigin := opts.allowsOrigin(origin)
+			if !allowed {
+				w.Header().Add("Vary", "Origin")
+				h.ServeHTTP(w, r)
+				return
+			}
+			methods := methodsFromRequest(r)
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight && len(methods) < 1 {
+				w.Header().Add("Vary", "Origin")
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			if anyOrigin {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Add("Vary", "Origin")
+			}
+			if opts.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if exposedHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			allow := allowHeader(methods)
+			header.Set("Allow", allow)
+			header.Set("Access-Control-Allow-Methods", allow)
+			if allowedHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowedHeaders)
+			} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			if maxAge != "" {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	type testCase struct {
+		name, method, url, origin string
+		preflight                 bool
+		status                    int
+		headers                   map[string]string
+	}
+	cases := []testCase{
+		{"preflight", "OPTIONS", "/posts/1", "https://example.com", true, http.StatusNoContent, map[string]string{
+			"Access-Control-Allow-Origin":      "https://example.com",
+			"Access-Control-Allow-Methods":     "DELETE, GET, OPTIONS",
+			"Allow":                            "DELETE, GET, OPTIONS",
+			"Access-Control-Allow-Headers":     "Authorization",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Max-Age":           "600",
+			"Vary":                             "Origin",
+		}},
+		{"simple", "GET", "/posts/1", "https://EXAMPLE.com", false, http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin":   "https://EXAMPLE.com",
+			"Access-Control-Expose-Headers": "X-Total",
+			"Access-Control-Allow-Methods":  "",
+		}},
+		{"disallowed origin", "OPTIONS", "/posts/1", "https://evil.com", true, http.StatusMethodNotAllowed, map[string]string{
+			"Access-Control-Allow-Origin": "",
+			"Vary":                        "Origin",
+		}},
+		{"no origin", "GET", "/posts/1", "", false, http.StatusOK, map[string]string{
+			"Access-Control-Allow-Origin": "",
+			"Vary":                        "Origin",
+		}},
+		{"not found", "OPTIONS", "/missing", "https://example.com", true, http.StatusNotFound, map[string]string{
+			"Access-Control-Allow-Origin": "",
+			"Vary":                        "Origin",
+		}},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	var router Router
+	router.SetMiddleware(CORS(CORSOptions{
+		AllowedOrigins:   []string{"https://example.com"},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	router.Endpoint("/posts/{id}").GET(ok).DELETE(ok)
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.name, err)
+		}
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if c.preflight {
+			r.Header.Set("Access-Control-Request-Method", "DELETE")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("Expected status %d for %s, got %d", c.status, c.name, w.Code)
+		}
+		for k, v := range c.headers {
+			if res := w.Header().Get(k); res != v {
+				t.Errorf("Expected %s header to be %q for %s, got %q", k, v, c.name, res)
+			}
+		}
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	var router Router
+	router.SetMiddleware(CORS(CORSOptions{AllowedOrigins: []string{"*"}}))
+	router.Endpoint("/posts").Methods("POST").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r, err := http.NewRequest("OPTIONS", "/posts", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	r.Header.Set("Origin", "https://anywhere.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "OPTIONS, POST",
+		"Access-Control-Allow-Headers": "Content-Type",
+		"Vary":                         "",
+	}
+	for k, v := range expected {
+		if res := w.Header().Get(k); res != v {
+			t.Errorf("Expected %s header to be %q, got %q", k, v, res)
+		}
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected allowing credentials from any origin to panic")
+		}
+	}()
+	CORS(CORSOptions{AllowedOrigins: []string{"https://example.com", "*"}, AllowCredentials: true})
+}
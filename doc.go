@@ -3,9 +3,9 @@ Package trout provides an opinionated router that's implemented
 using a basic trie.
 
 The router is opinionated and biased towards basic RESTful services. Its main
-constraint is that its URL templating is very basic and has no support for
-regular expressions or anything other than a direct equality comparison or
-prefix match, unlike many routing libraries.
+constraint is that its URL templating is very basic: path elements are matched
+by a direct equality comparison or prefix match, and parameters can only be
+limited by an opt-in regular expression constraint, like {id:[0-9]+}.
 
 The router is specifically designed to support users that want to return
 correct information with OPTIONS requests, so it enables users to retrieve a
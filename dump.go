@@ -0,0 +1,73 @@
+package trout
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// Dump writes a description of every node in the trie of `router` to `w`,
+// as an indented tree, for debugging. Each path element is written using
+// the same format as the URL templates it came from, with `{::NULL::}`
+// marking the end of an Endpoint or Prefix, followed by the methods it has
+// handlers for. The empty path element, which the template `/` ends in, is
+// written as `""`. A default handler set using the Handler method is listed as
+// "*". Nodes are listed in the same order as Routes lists routes.
+func (router Router) Dump(w io.Writer) error {
+	_, err := io.WriteString(w, router.String())
+	return err
+}
+
+// String returns the description of `router` that Dump writes.
+func (router Router) String() string {
+	if router.trie == nil {
+		return ""
+	}
+	router.trie.RLock()
+	defer router.trie.RUnlock()
+
+	var b strings.Builder
+	b.WriteString("/\n")
+	dumpNode(&b, router.trie.root, 1)
+	for _, h := range router.trie.hosts {
+		b.WriteString(h.root.host + "/\n")
+		dumpNode(&b, h.root, 1)
+	}
+	return b.String()
+}
+
+// dumpNode writes the children of `n` to `b`, indented by `depth` levels,
+// followed by their own children.
+func dumpNode(b *strings.Builder, n *node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if n.terminator != nil {
+		dumpTerminator(b, n.terminator, indent)
+		for _, variant := range n.terminator.queryVariants {
+			dumpTerminator(b, variant, indent)
+		}
+	}
+	children := append(n.staticChildren(), n.wildChildren...)
+	for _, child := range children {
+		value := child.value.String()
+		if value == "" {
+			// the empty path element, as in the template `/`
+			value = `""`
+		}
+		b.WriteString(indent + value + "\n")
+		dumpNode(b, child, depth+1)
+	}
+}
+
+// dumpTerminator writes the terminator `n` and its methods to `b`.
+func dumpTerminator(b *strings.Builder, n *node, indent string) {
+	methods := make([]string, 0, len(n.methods))
+	for method := range n.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	b.WriteString(indent + n.value.String())
+	if len(n.query) > 0 {
+		b.WriteString("?" + queryString(n))
+	}
+	b.WriteString(" [" + strings.Join(methods, ", ") + "]\n")
+}
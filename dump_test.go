@@ -0,0 +1,38 @@
+package trout
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	var router Router
+	router.Endpoint("/posts/{id:int}").Methods("GET", "DELETE").Handler(testHandler("post"))
+	router.Endpoint("/posts").Handler(testHandler("posts"))
+	router.Endpoint("/api/v1/users").GET(testHandler("users"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Host("{tenant}.example.com").Endpoint("/").Handler(testHandler("tenant"))
+
+	expected := strings.Join([]string{
+		"/",
+		"  api/v1/users",
+		"    {::NULL::} [GET]",
+		"  posts",
+		"    {::NULL::} [*]",
+		"    {id:int}",
+		"      {::NULL::} [DELETE, GET]",
+		"  static::prefix",
+		"    {::NULL::} [*]",
+		"{tenant}.example.com/",
+		`  ""`,
+		"    {::NULL::} [*]",
+		"",
+	}, "\n")
+	var b strings.Builder
+	if err := router.Dump(&b); err != nil {
+		t.Fatalf("Unexpected error dumping router: %+v", err)
+	}
+	if b.String() != expected {
+		t.Errorf("Expected dump to be:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
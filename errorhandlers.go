@@ -0,0 +1,100 @@
+package trout
+
+import "net/http"
+
+// NotFound sets `h` as the http.Handler used to respond with a 404 to
+// requests that match `e` but that `e` has no handler for, and to requests
+// under `e` that no Endpoint or Prefix matches. For example, NotFound on the
+// Endpoint `/api` will be used for a request to `/api/missing` if nothing
+// else matches it. When more than one Endpoint or Prefix along the request's
+// path has a NotFound handler, the one closest to the end of the path is
+// used. Requests that no NotFound handler applies to use the Router's 404
+// handler. It returns `e`, so more methods can be chained.
+//
+// NotFound is not concurrency-safe, and should not be used while the Router
+// `e` belongs to is actively routing traffic.
+func (e *Endpoint) NotFound(h http.Handler) *Endpoint {
+	(*node)(e).notFound = h
+	return e
+}
+
+// MethodNotAllowed sets `h` as the http.Handler used to respond with a 405 to
+// requests that match `e`, or an Endpoint or Prefix under `e`, but were made
+// using a method they have no handler for. As with NotFound, the handler
+// closest to the end of the request's path is used, and the Router's 405
+// handler is used if none applies. It returns `e`, so more methods can be
+// chained.
+//
+// MethodNotAllowed is not concurrency-safe, and should not be used while the
+// Router `e` belongs to is actively routing traffic.
+func (e *Endpoint) MethodNotAllowed(h http.Handler) *Endpoint {
+	(*node)(e).methodNotAllowed = h
+	return e
+}
+
+// NotFound sets `h` as the http.Handler used to respond with a 404 to
+// requests that match `p` but that `p` has no handler for, and to requests
+// under `p` that no Endpoint or Prefix matches, following the same rules as
+// Endpoint.NotFound.
+//
+// NotFound is not concurrency-safe, and should not be used while the Router
+// `p` belongs to is actively routing traffic.
+func (p *Prefix) NotFound(h http.Handler) *Prefix {
+	(*node)(p).notFound = h
+	return p
+}
+
+// MethodNotAllowed sets `h` as the http.Handler used to respond with a 405 to
+// requests that match `p`, or an Endpoint or Prefix under `p`, but were made
+// using a method they have no handler for, following the same rules as
+// Endpoint.MethodNotAllowed.
+//
+// MethodNotAllowed is not concurrency-safe, and should not be used while the
+// Router `p` belongs to is actively routing traffic.
+func (p *Prefix) MethodNotAllowed(h http.Handler) *Prefix {
+	(*node)(p).methodNotAllowed = h
+	return p
+}
+
+// nearestHandler returns the handler `pick` returns for the closest
+// terminator at or above `n`, or nil if `pick` returns nil for all of them.
+// Prefixes are kept apart from the static nodes for the same path element,
+// so the Prefix alongside each static node on the way up is checked too.
+func nearestHandler(n *node, pick func(*node) http.Handler) http.Handler {
+	if n != nil && n.value.nul {
+		n = n.parent
+	}
+	for ; n != nil; n = n.parent {
+		if n.terminator != nil {
+			if h := pick(n.terminator); h != nil {
+				return h
+			}
+		}
+		if prefix := prefixSibling(n); prefix != nil && prefix.terminator != nil {
+			if h := pick(prefix.terminator); h != nil {
+				return h
+			}
+		}
+	}
+	return nil
+}
+
+// prefixSibling returns the Prefix node for the first path element the
+// static node `n` matches, if its parent has one.
+func prefixSibling(n *node) *node {
+	if n.parent == nil || n.value.dynamic || n.value.prefix {
+		return nil
+	}
+	return n.parent.prefixChildren[n.allSegments()[0]]
+}
+
+// closestNode returns the deepest node in the trie that the path of `r`
+// leads to, even if no Endpoint or Prefix matches it, or nil if `router` has
+// no Endpoints or Prefixes.
+func (router Router) closestNode(r *http.Request) *node {
+	if router.trie == nil {
+		return nil
+	}
+	_, pieces := router.splitPath(r, nil)
+	return router.trie.closestNode(requestHost(r), pieces, router.fold(nil, pieces), router.SegmentMatcher)
+}
@@ -0,0 +1,53 @@
+package trout
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNodeErrorHandlers(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/api/users", "users"},
+		{"POST", "/api/users", "api-405"},
+		{"GET", "/api/users/123/missing", "api-404"},
+		{"GET", "/api", "api-404"},
+		{"GET", "/api/posts", "posts"},
+		{"DELETE", "/api/posts", "posts-405"},
+		{"GET", "/api/posts/missing", "posts-404"},
+		{"GET", "/page", "page"},
+		{"POST", "/page", "router-405"},
+		{"GET", "/missing", "router-404"},
+		{"GET", "/page/missing", "router-404"},
+		{"GET", "/static/site.css", "static"},
+		{"POST", "/static/site.css", "static-405"},
+		{"GET", "/files/readme", "readme"},
+		{"POST", "/files/readme", "files-405"},
+		{"GET", "/files/readme/missing", "files-404"},
+		{"GET", "/files/docs/guide", "guide"},
+		{"PUT", "/files/docs/guide", "files-405"},
+	}
+	var router Router
+	router.Handle404 = testHandler("router-404")
+	router.Handle405 = testHandler("router-405")
+	router.Endpoint("/api").NotFound(testHandler("api-404")).MethodNotAllowed(testHandler("api-405"))
+	router.Endpoint("/api/users").GET(testHandler("users"))
+	router.Endpoint("/api/posts").GET(testHandler("posts")).NotFound(testHandler("posts-404")).MethodNotAllowed(testHandler("posts-405"))
+	router.Endpoint("/page").GET(testHandler("page"))
+	router.Prefix("/static").GET(testHandler("static")).MethodNotAllowed(testHandler("static-405"))
+	router.Prefix("/files").NotFound(testHandler("files-404")).MethodNotAllowed(testHandler("files-405"))
+	router.Endpoint("/files/readme").GET(testHandler("readme"))
+	router.Endpoint("/files/docs/guide").GET(testHandler("guide"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package trout
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ETag returns middleware that handles If-None-Match for requests, using
+// `hash` to compute the current ETag of what they ask for. The ETag is set
+// in the response's ETag header, quoted if `hash` didn't quote it, and if
+// the request's If-None-Match header matches it, the handler isn't called:
+// GET and HEAD requests get a 304 response, and requests using other methods
+// get a 412, as required by RFC 9110. ETags are compared using the weak
+// comparison If-None-Match calls for, so `W/"v1"` matches `"v1"`. If `hash`
+// returns an empty string, the request is passed on as it is.
+//
+// ETag is meant to be used as middleware on an Endpoint or Prefix, set using
+// Methods.Middleware, so `hash` can use the parameters and pattern of the
+// request. It only writes to the response, so the request's Trout- headers
+// are left untouched, and the statuses it sends are seen by middleware like
+// Metrics and AccessLog.
+func ETag(hash func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tag := hash(r)
+			if tag == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			tag = quoteETag(tag)
+			w.Header().Set("ETag", tag)
+			if !etagMatches(r.Header.Values("If-None-Match"), tag) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusPreconditionFailed)
+		})
+	}
+}
+
+// quoteETag returns `tag` as an entity tag, in quotes, keeping any `W/`
+// prefix outside them.
+func quoteETag(tag string) string {
+	weak := strings.HasPrefix(tag, "W/")
+	tag = strings.TrimPrefix(tag, "W/")
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		tag = `"` + tag + `"`
+	}
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// etagMatches returns true if any of the If-None-Match header values in
+// `headers` match `tag`, using weak comparison.
+func etagMatches(headers []string, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, header := range headers {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
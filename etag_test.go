@@ -0,0 +1,62 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	type testCase struct {
+		method, url, ifNoneMatch string
+		code                     int
+		etag                     string
+	}
+	cases := []testCase{
+		{"GET", "/posts/foo", "", http.StatusOK, `"foo-v1"`},
+		{"GET", "/posts/foo", `"foo-v1"`, http.StatusNotModified, `"foo-v1"`},
+		{"HEAD", "/posts/foo", `W/"foo-v1"`, http.StatusNotModified, `"foo-v1"`},
+		{"GET", "/posts/foo", `"bar-v1", "foo-v1"`, http.StatusNotModified, `"foo-v1"`},
+		{"GET", "/posts/foo", `"foo-v0"`, http.StatusOK, `"foo-v1"`},
+		{"GET", "/posts/foo", "*", http.StatusNotModified, `"foo-v1"`},
+		{"PUT", "/posts/foo", "*", http.StatusPreconditionFailed, `"foo-v1"`},
+		{"GET", "/posts/weak", `"weak"`, http.StatusNotModified, `W/"weak"`},
+		{"GET", "/posts/none", "*", http.StatusOK, ""},
+	}
+	var rec testRecorder
+	var router Router
+	router.SetMiddleware(Metrics(&rec))
+	router.Endpoint("/posts/{slug}").Methods("GET", "HEAD", "PUT").Middleware(ETag(func(r *http.Request) string {
+		switch slug := RequestVars(r).Get("slug"); slug {
+		case "none":
+			return ""
+		case "weak":
+			return `W/"weak"`
+		default:
+			return slug + "-v1"
+		}
+	})).Handler(testHandler("post"))
+	for i, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		if c.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", c.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s %s with %q to return %d, got %d", c.method, c.url, c.ifNoneMatch, c.code, w.Code)
+		}
+		if etag := w.Header().Get("ETag"); etag != c.etag {
+			t.Errorf("Expected %s %s to have an ETag of %q, got %q", c.method, c.url, c.etag, etag)
+		}
+		if c.code != http.StatusOK && w.Body.Len() != 0 {
+			t.Errorf("Expected %s %s with %q to have no body, got %q", c.method, c.url, c.ifNoneMatch, w.Body.String())
+		}
+		if len(rec) != i+1 || rec[i].status != c.code || rec[i].pattern != "/posts/{slug}" {
+			t.Errorf("Expected %s %s to be observed as a %d, got %v", c.method, c.url, c.code, rec)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package trout
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Candidate describes an Endpoint or Prefix that was considered when routing
+// a request, and how it was scored.
+type Candidate struct {
+	// Pattern is the URL template of the Endpoint or Prefix, as it would
+	// be set in the Trout-Pattern header.
+	Pattern string
+	// Query holds the query parameters the Endpoint requires, if it was
+	// created using Endpoint.Query, the way they'd be written in a query
+	// string.
+	Query string
+	// Score is how good a match the Endpoint or Prefix is for the
+	// request's path, without taking the method into account. It holds
+	// the weight of each path element, starting from the root: static
+	// path elements weigh more than constrained dynamic path elements,
+	// which weigh more than dynamic path elements and prefixes. A longer
+	// Score always beats a shorter one; Scores of the same length are
+	// decided by the first weight that differs.
+	Score []int
+	// SupportsMethod is true if the Endpoint or Prefix has a handler for
+	// the request's method. Candidates that do are always selected over
+	// Candidates that don't, regardless of Score or Priority.
+	SupportsMethod bool
+	// Priority is the priority set on the Endpoint or Prefix. Among
+	// Candidates that have a handler for the request's method, the one
+	// with the highest Priority is selected, regardless of Score.
+	Priority int
+	// Selected is true for the Candidate that was chosen to serve the
+	// request.
+	Selected bool
+}
+
+// String returns a human-readable description of `c`.
+func (c Candidate) String() string {
+	pattern := c.Pattern
+	if c.Query != "" {
+		pattern += "?" + c.Query
+	}
+	res := fmt.Sprintf("%s score=%v priority=%d", pattern, c.Score, c.Priority)
+	if !c.SupportsMethod {
+		res += " (method not supported)"
+	}
+	if c.Selected {
+		res += " (selected)"
+	}
+	return res
+}
+
+// Explain returns every Endpoint and Prefix that matched the path of a
+// request made using `method` for `path`, with the score each was given and
+// which was selected to serve the request. `path` may include a query
+// string, which is used to match Endpoints created using Endpoint.Query;
+// those that don't match it aren't returned. `path` may also be an absolute
+// URL, whose host is used to match Hosts. Candidates are returned in the
+// order they were found, which is stable for a given Router and path. A
+// request that matched nothing returns nil.
+//
+// Explain is meant for debugging why a request was routed the way it was.
+func (router Router) Explain(method, path string) []Candidate {
+	if router.trie == nil {
+		return nil
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil
+	}
+	r := &http.Request{Method: method, URL: u, Host: u.Host}
+	_, pieces := router.splitPath(r, nil)
+	nodes, _ := router.trie.findHostNodes(requestHost(r), pieces, router.fold(nil, pieces), router.SegmentMatcher)
+	query := &requestQuery{raw: u.RawQuery}
+	selected := pickNode(nodes, method, query)
+
+	var candidates []Candidate
+	for _, n := range nodes {
+		if n == nil || n.terminator == nil {
+			continue
+		}
+		terms := append([]*node{n.terminator}, n.terminator.queryVariants...)
+		for _, term := range terms {
+			if !matchesQuery(term, query) {
+				continue
+			}
+			candidates = append(candidates, Candidate{
+				Pattern:        router.trie.pattern(router.prefix, term),
+				Query:          queryString(term),
+				Score:          scoreWeights(n),
+				SupportsMethod: supportsMethod(term, method),
+				Priority:       term.priority,
+				Selected:       term == selected,
+			})
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,39 @@
+package trout
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	var router Router
+	router.Endpoint("/{id}").Methods("GET").Handler(testHandler("id"))
+	router.Endpoint("/v1").Methods("POST").Handler(testHandler("v1"))
+	router.Prefix("/{id}").Methods("GET").Handler(testHandler("prefix"))
+
+	expected := []Candidate{
+		{Pattern: "/v1", Score: []int{3, 3}},
+		{Pattern: "/{id}", Score: []int{3, 1}, SupportsMethod: true, Selected: true},
+		{Pattern: "/{id::prefix}", Score: []int{3, 1}, SupportsMethod: true},
+	}
+	candidates := router.Explain("GET", "/v1")
+	if !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Expected candidates to be %+v, got %+v", expected, candidates)
+	}
+	if s := candidates[1].String(); s != "/{id} score=[3 1] priority=0 (selected)" {
+		t.Errorf("Unexpected string for candidate: %q", s)
+	}
+
+	if s := candidates[0].String(); s != "/v1 score=[3 3] priority=0 (method not supported)" {
+		t.Errorf("Unexpected string for candidate: %q", s)
+	}
+
+	if candidates := router.Explain("GET", "/v1/foo/bar"); len(candidates) != 1 || candidates[0].Pattern != "/{id::prefix}" || !candidates[0].Selected {
+		t.Errorf("Expected only the prefix to be selected, got %+v", candidates)
+	}
+
+	var empty Router
+	if candidates := empty.Explain("GET", "/"); candidates != nil {
+		t.Errorf("Expected no candidates for an empty router, got %+v", candidates)
+	}
+}
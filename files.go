@@ -0,0 +1,143 @@
+package trout
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// StripPrefix returns an http.Handler that serves requests with `h`, after
+// replacing the request's URL path with the part of it after the matched
+// Prefix, as returned by PrefixRemainder. So if the Prefix `/static`
+// matched a request for `/static/css/site.css`, `h` will see a request for
+// `/css/site.css`. The Prefix may contain parameters; only the path after the
+// whole Prefix is kept. A trailing slash in the request path is kept.
+//
+// StripPrefix is meant to be used as the handler for a Prefix, to serve
+// handlers like http.FileServer that expect to be at the root of the URL
+// space.
+func StripPrefix(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := "/" + PrefixRemainder(r)
+		if p != "/" && strings.HasSuffix(r.URL.Path, "/") {
+			p += "/"
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+		h.ServeHTTP(w, r2)
+	})
+}
+
+// ServeFiles sets the default http.Handler for `p` to serve files from
+// `root`, using the part of the request path after `p` as the path of the
+// file, as described by StripPrefix. It returns `p`, so more methods can be
+// chained.
+//
+// ServeFiles is not concurrency-safe, and should not be used while the Router
+// `p` belongs to is actively routing traffic.
+func (p *Prefix) ServeFiles(root http.FileSystem) *Prefix {
+	return p.Handler(StripPrefix(http.FileServer(root)))
+}
+
+// ServeFiles defines a new Prefix on the Router that serves files from
+// `root`, using the part of the request path after the Prefix as the path of
+// the file, as described by StripPrefix. `pattern` follows the same rules as
+// Router.Prefix, and ServeFiles panics if it isn't valid.
+//
+// Requests http.FileServer would answer with a 404 are served using the same
+// 404 handler as requests no Endpoint or Prefix matches instead, so error
+// pages stay consistent.
+// Requests with a `..` path element are always treated as not found, so they
+// can't reach outside `root`. The Prefix is returned, so that more methods
+// can be called on it.
+func (router *Router) ServeFiles(pattern string, root http.FileSystem) *Prefix {
+	p := router.Prefix(pattern)
+	files := StripPrefix(http.FileServer(root))
+	return p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := PrefixRemainder(r)
+		for _, piece := range strings.Split(name, "/") {
+			if piece == ".." {
+				router.get404((*node)(p)).ServeHTTP(w, r)
+				return
+			}
+		}
+		nw := &notFoundResponseWriter{ResponseWriter: w, header: http.Header{}}
+		files.ServeHTTP(nw, r)
+		if nw.notFound {
+			router.get404((*node)(p)).ServeHTTP(w, r)
+			return
+		}
+		if !nw.wroteHeader {
+			nw.WriteHeader(http.StatusOK)
+		}
+	}))
+}
+
+// notFoundResponseWriter is an http.ResponseWriter that holds back a 404
+// response, and any headers set for it, so a different handler can respond
+// instead. Responses with any other status are passed through as they are.
+type notFoundResponseWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	notFound    bool
+	wroteHeader bool
+}
+
+// Header returns the headers that will be sent with the response, unless
+// it's a 404.
+func (w *notFoundResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader sends the held-back headers and `status`, unless `status` is
+// a 404, in which case nothing is sent.
+func (w *notFoundResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status == http.StatusNotFound {
+		w.notFound = true
+		return
+	}
+	header := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes `b` to the response, or discards it if the response is a 404.
+func (w *notFoundResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notFound {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// ReadFrom copies `r` to the response, using the wrapped
+// http.ResponseWriter's ReadFrom method if it has one, so files can still be
+// sent using sendfile, or discards it if the response is a 404.
+func (w *notFoundResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notFound {
+		return io.Copy(io.Discard, r)
+	}
+	return io.Copy(w.ResponseWriter, r)
+}
+
+// Unwrap returns the http.ResponseWriter being wrapped, for use with
+// http.ResponseController.
+func (w *notFoundResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
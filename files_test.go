@@ -0,0 +1,191 @@
+package trout
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStripPrefix(t *testing.T) {
+	type testCase struct {
+		url, path string
+	}
+	cases := []testCase{
+		{"/static/css/site.css", "/css/site.css"},
+		{"/static/css/", "/css/"},
+		{"/static", "/"},
+		{"/static/", "/"},
+		{"/users/foo/files/a%20b.txt", "/a b.txt"},
+	}
+	var path string
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	})
+	var router Router
+	router.Prefix("/static").Handler(StripPrefix(record))
+	router.Prefix("/users/{id}/files").Handler(StripPrefix(record))
+	for _, c := range cases {
+		path = "unset"
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		original := r.URL.Path
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if path != c.path {
+			t.Errorf("Expected %s to be served as %q, got %q", c.url, c.path, path)
+		}
+		if r.URL.Path != original {
+			t.Errorf("Expected the original request for %s to be left alone, got %q", c.url, r.URL.Path)
+		}
+	}
+}
+
+func TestPrefixServeFiles(t *testing.T) {
+	type testCase struct {
+		url    string
+		status int
+		body   string
+	}
+	cases := []testCase{
+		{"/users/foo/files/hello.txt", http.StatusOK, "hello"},
+		{"/users/foo/files/docs/readme.txt", http.StatusOK, "readme"},
+		{"/users/foo/files/missing.txt", http.StatusNotFound, ""},
+	}
+	fsys := fstest.MapFS{
+		"hello.txt":       {Data: []byte("hello")},
+		"docs/readme.txt": {Data: []byte("readme")},
+	}
+	var router Router
+	router.Prefix("/users/{id}/files").ServeFiles(http.FS(fsys))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("Expected status %d for %s, got %d", c.status, c.url, w.Code)
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Errorf("Expected body %q for %s, got %q", c.body, c.url, w.Body.String())
+		}
+	}
+}
+
+func TestRouterServeFiles(t *testing.T) {
+	type testCase struct {
+		url    string
+		status int
+		body   string
+	}
+	cases := []testCase{
+		{"/static/site.css", http.StatusOK, "body{}"},
+		{"/static/js/app.js", http.StatusOK, "app()"},
+		{"/static/missing.css", http.StatusNotFound, "custom 404"},
+		{"/static/js/../site.css", http.StatusNotFound, "custom 404"},
+		{"/static/js/%2e%2e/site.css", http.StatusNotFound, "custom 404"},
+		{"/missing", http.StatusNotFound, "custom 404"},
+	}
+	fsys := fstest.MapFS{
+		"site.css":  {Data: []byte("body{}")},
+		"js/app.js": {Data: []byte("app()")},
+	}
+	var router Router
+	router.ServeFiles("/static", http.FS(fsys))
+	router.Handle404 = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("custom 404")) //nolint:errcheck
+	})
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("Expected status %d for %s, got %d", c.status, c.url, w.Code)
+		}
+		if w.Body.String() != c.body {
+			t.Errorf("Expected body %q for %s, got %q", c.body, c.url, w.Body.String())
+		}
+	}
+}
+
+// countingFS is an http.FileSystem that counts how many times each file is
+// opened.
+type countingFS struct {
+	http.FileSystem
+	opened map[string]int
+}
+
+func (fs countingFS) Open(name string) (http.File, error) {
+	fs.opened[name]++
+	return fs.FileSystem.Open(name)
+}
+
+func TestRouterServeFilesOpensOnce(t *testing.T) {
+	fsys := countingFS{
+		FileSystem: http.FS(fstest.MapFS{"site.css": {Data: []byte("body{}")}}),
+		opened:     map[string]int{},
+	}
+	var router Router
+	router.ServeFiles("/static", fsys)
+	router.Handle404 = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("custom 404")) //nolint:errcheck
+	})
+	for _, path := range []string{"/static/site.css", "/static/missing.css"} {
+		r, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", path, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		name := strings.TrimPrefix(path, "/static")
+		if n := fsys.opened[name]; n != 1 {
+			t.Errorf("Expected %s to be opened once, was opened %d times", name, n)
+		}
+		if w.Code == http.StatusNotFound && w.Header().Get("X-Content-Type-Options") != "" {
+			t.Errorf("Expected no headers from http.FileServer on a 404, got %v", w.Header())
+		}
+	}
+}
+
+// readerFromRecorder is an httptest.ResponseRecorder that records whether its
+// ReadFrom method was used.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+func TestRouterServeFilesReadFrom(t *testing.T) {
+	var router Router
+	router.ServeFiles("/static", http.FS(fstest.MapFS{"site.css": {Data: []byte("body{}")}}))
+	r, err := http.NewRequest("GET", "/static/site.css", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	w := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "body{}" {
+		t.Errorf("Expected the file to be served, got %d %q", w.Code, w.Body.String())
+	}
+	if !w.readFrom {
+		t.Errorf("Expected the file to be sent using ReadFrom")
+	}
+	nw := &notFoundResponseWriter{ResponseWriter: w, header: http.Header{}}
+	if unwrapped := nw.Unwrap(); unwrapped != w {
+		t.Errorf("Expected Unwrap to return the wrapped writer, got %v", unwrapped)
+	}
+}
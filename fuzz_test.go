@@ -0,0 +1,71 @@
+package trout
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// fuzzRouter returns a Router with a fixed set of routes that covers each
+// kind of node, for fuzzing the matcher.
+func fuzzRouter() *Router {
+	router := NewRouter(WithHandleOPTIONS(), WithHandleHEAD(), WithRedirectTrailingSlash())
+	router.Endpoint("/").GET(testHandler("root"))
+	router.Endpoint("/posts").Methods("GET", "POST").Handler(testHandler("posts"))
+	router.Endpoint("/posts/{id:int}").GET(testHandler("post"))
+	router.Endpoint("/posts/{slug}/comments/{id}").Handler(testHandler("comment"))
+	router.Endpoint("/posts/{id}").Query("draft", "").GET(testHandler("draft"))
+	router.Endpoint("/files/img-{name}.png").Handler(testHandler("image"))
+	router.Endpoint("/orders/{status:open|closed}").Handler(testHandler("order"))
+	router.Endpoint("/api/v1/users/list").Handler(testHandler("users"))
+	router.Endpoint("/docs/{path...}").Handler(testHandler("docs"))
+	router.Endpoint("/{a}/{b}/{a}").Handler(testHandler("repeated"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Prefix("/assets/{version}").GET(testHandler("assets"))
+	router.Host("{tenant}.example.com").Endpoint("/users/{id}").Handler(testHandler("tenant"))
+	return router
+}
+
+func FuzzRouting(f *testing.F) {
+	seeds := []struct {
+		method, path string
+	}{
+		{"GET", "/"},
+		{"GET", ""},
+		{"GET", "//"},
+		{"GET", "/posts//1"},
+		{"GET", "/posts/a%2Fb/comments/c"},
+		{"GET", "/posts/%"},
+		{"GET", "/posts/1?draft"},
+		{"POST", "/posts/"},
+		{"OPTIONS", "*"},
+		{"HEAD", "/assets/v1/app.js"},
+		{"GET", "/files/img-.png"},
+		{"GET", "/docs/a/b/c/d/e/f/g/h/i/j/k/l/m/n/o/p/q"},
+		{"GET", "/café/ünïcödé/日本語"},
+		{"GET", "/ORDERS/Open"},
+		{"GET", "http://acme.example.com/users/1"},
+		{"DELETE", "/a/b/a/"},
+		{"GET", "/../../etc/passwd"},
+	}
+	for _, seed := range seeds {
+		f.Add(seed.method, seed.path)
+	}
+	router := fuzzRouter()
+	f.Fuzz(func(t *testing.T, method, path string) {
+		if m := router.Match(method, path); m.Kind == MatchNotFound && m.Pattern != "" {
+			t.Errorf("Expected no pattern for %s %q, got %+v", method, path, m)
+		}
+		router.Explain(method, path)
+		router.AllowedMethods(path)
+
+		u, err := url.Parse(path)
+		if err != nil {
+			return
+		}
+		r := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+		if h, _ := router.getHandler(r); h == nil {
+			t.Errorf("Expected a handler for %s %q, got nil", method, path)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package trout
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group is a set of Endpoints and Prefixes on a Router that share a URL
+// template prefix and middleware. It is only valid to instantiate a Group by
+// calling `Router.Group` or `Group.Group`.
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware []func(http.Handler) http.Handler
+}
+
+// Group returns a Group whose Endpoints and Prefixes will be defined on
+// `router`, with `prefix` prepended to their URL templates. The middleware
+// passed to Group will run for every request routed to the Group's Endpoints
+// and Prefixes, after any Router middleware but before any middleware set on
+// the Endpoints, Prefixes, or Methods themselves.
+//
+// `prefix` is a URL template, and may contain parameters, which will be
+// available to the Group's Endpoints and Prefixes like any other parameter.
+//
+// An Endpoint or Prefix always uses the middleware of the last Group it was
+// defined through.
+func (router *Router) Group(prefix string, mw ...func(http.Handler) http.Handler) *Group {
+	return &Group{
+		router:     router,
+		prefix:     prefix,
+		middleware: mw,
+	}
+}
+
+// Group returns a Group nested within `g`. Its Endpoints and Prefixes will
+// have the prefix of `g` prepended to `prefix`, and the middleware of `g` will
+// run before `mw`.
+func (g *Group) Group(prefix string, mw ...func(http.Handler) http.Handler) *Group {
+	middleware := make([]func(http.Handler) http.Handler, 0, len(g.middleware)+len(mw))
+	middleware = append(middleware, g.middleware...)
+	middleware = append(middleware, mw...)
+	return &Group{
+		router:     g.router,
+		prefix:     joinTemplates(g.prefix, prefix),
+		middleware: middleware,
+	}
+}
+
+// Endpoint defines a new Endpoint on the Router `g` belongs to, with the
+// prefix of `g` prepended to `e`, following the same rules as
+// Router.Endpoint.
+func (g *Group) Endpoint(e string) *Endpoint {
+	endpoint, err := g.AddEndpoint(e)
+	if err != nil {
+		panic(err)
+	}
+	return endpoint
+}
+
+// AddEndpoint defines a new Endpoint on the Router `g` belongs to, exactly
+// like Endpoint, but returns an error instead of panicking if the URL template
+// isn't valid.
+func (g *Group) AddEndpoint(e string) (*Endpoint, error) {
+	endpoint, err := g.router.AddEndpoint(joinTemplates(g.prefix, e))
+	if err != nil {
+		return nil, err
+	}
+	(*node)(endpoint).groupMiddleware = g.middleware
+	return endpoint, nil
+}
+
+// Prefix defines a new Prefix on the Router `g` belongs to, with the prefix
+// of `g` prepended to `p`, following the same rules as Router.Prefix.
+func (g *Group) Prefix(p string) *Prefix {
+	prefix, err := g.AddPrefix(p)
+	if err != nil {
+		panic(err)
+	}
+	return prefix
+}
+
+// AddPrefix defines a new Prefix on the Router `g` belongs to, exactly like
+// Prefix, but returns an error instead of panicking if the URL template isn't
+// valid.
+func (g *Group) AddPrefix(p string) (*Prefix, error) {
+	prefix, err := g.router.AddPrefix(joinTemplates(g.prefix, p))
+	if err != nil {
+		return nil, err
+	}
+	(*node)(prefix).groupMiddleware = g.middleware
+	return prefix, nil
+}
+
+// joinTemplates returns a URL template made up of `prefix` followed by `t`,
+// with a single `/` between them. If `t` is empty or `/`, `prefix` is
+// returned without a trailing slash, so the root of a Group doesn't end in
+// one, just like the template `/` doesn't for a Router.
+func joinTemplates(prefix, t string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if t == "" || t == "/" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	return prefix + "/" + strings.TrimPrefix(t, "/")
+}
@@ -0,0 +1,85 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	type testCase struct {
+		method, url, body, trace string
+	}
+	cases := []testCase{
+		{"GET", "/api/v1/posts", "posts", "router,api,v1,endpoint"},
+		{"GET", "/api/v1/posts/foo", "post", "router,api,v1"},
+		{"POST", "/api/v1/posts/foo", "post-create", "router,api,v1,method"},
+		{"GET", "/api/health", "health", "router,api"},
+		{"GET", "/api/static/css/site.css", "static", "router,api"},
+		{"GET", "/outside", "outside", "router"},
+	}
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Trace", name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	var router Router
+	router.SetMiddleware(trace("router"))
+	api := router.Group("/api/", trace("api"))
+	v1 := api.Group("v1", trace("v1"))
+	v1.Endpoint("/posts").Middleware(trace("endpoint")).Handler(testHandler("posts"))
+	v1.Endpoint("/posts/{slug}").Handler(testHandler("post"))
+	v1.Endpoint("/posts/{slug}").Methods("POST").Middleware(trace("method")).Handler(testHandler("post-create"))
+	api.Endpoint("/health").Handler(testHandler("health"))
+	api.Prefix("/static").Handler(testHandler("static"))
+	router.Endpoint("/outside").Handler(testHandler("outside"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected %s %s to be served by %s, got %s", c.method, c.url, c.body, body)
+		}
+		if tr := strings.Join(w.Header()["Trace"], ","); tr != c.trace {
+			t.Errorf("Expected %s %s to run middleware %s, got %s", c.method, c.url, c.trace, tr)
+		}
+	}
+}
+
+func TestGroupRoot(t *testing.T) {
+	type testCase struct {
+		url, location string
+		code          int
+	}
+	cases := []testCase{
+		{"/api", "", http.StatusOK},
+		{"/api/", "/api", http.StatusMovedPermanently},
+		{"/v2", "", http.StatusOK},
+		{"/", "", http.StatusOK},
+	}
+	router := NewRouter(WithRedirectTrailingSlash())
+	router.Group("/api").Endpoint("/").Handler(testHandler("api"))
+	router.Group("/v2/").Endpoint("").Handler(testHandler("v2"))
+	router.Group("").Endpoint("").Handler(testHandler("root"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s to return %d, got %d", c.url, c.code, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != c.location {
+			t.Errorf("Expected %s to redirect to %q, got %q", c.url, c.location, loc)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package trout
+
+import (
+	"compress/gzip"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressedTypes are the media types of content that's already compressed,
+// which Gzip doesn't compress again. Every image, audio, and video type is
+// treated as compressed, except those listed in uncompressedTypes.
+var compressedTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zip":              true,
+	"application/x-bzip2":          true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/x-xz":             true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+}
+
+// uncompressedTypes are the image types that aren't compressed, and so are
+// worth compressing.
+var uncompressedTypes = map[string]bool{
+	"image/svg+xml": true,
+	"image/bmp":     true,
+	"image/x-icon":  true,
+}
+
+// gzipWriterPools holds a pool of gzip.Writers for each compression level.
+var gzipWriterPools sync.Map
+
+// Gzip returns middleware that compresses responses using gzip at `level`,
+// one of the compress/gzip levels, like gzip.DefaultCompression, for
+// requests whose Accept-Encoding header accepts it. Responses that already
+// have a Content-Encoding, responses without a body, partial responses, and
+// responses whose Content-Type is already compressed, like images, videos,
+// and archives, are sent as they are. Responses without a Content-Type have
+// it detected from their uncompressed body, like net/http would. Gzip panics
+// if `level` isn't a valid compression level.
+//
+// The http.ResponseWriter Gzip passes on can be wrapped by other middleware,
+// like Metrics and AccessLog, and can wrap theirs, so they can be set in any
+// order. Flushing it, directly or through http.ResponseController, flushes
+// the compressed data written so far.
+func Gzip(level int) func(http.Handler) http.Handler {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		panic(fmt.Errorf("trout: %w", err))
+	}
+	pool, _ := gzipWriterPools.LoadOrStore(level, &sync.Pool{New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(nil, level)
+		return gz
+	}})
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipResponseWriter{ResponseWriter: w, pool: pool.(*sync.Pool), head: r.Method == http.MethodHead}
+			defer gw.close()
+			h.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip returns true if the value of an Accept-Encoding header,
+// `header`, accepts gzip, either by name or through `*`, with a non-zero
+// quality.
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "x-gzip" && name != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		if quality, err := strconv.ParseFloat(q, 64); err == nil && quality > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible returns true if the media type in the Content-Type header
+// `contentType` isn't already compressed.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	if compressedTypes[mediaType] {
+		return false
+	}
+	if uncompressedTypes[mediaType] {
+		return true
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// gzipResponseWriter is an http.ResponseWriter that compresses the body
+// written to it, if it decides to once the response starts.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	pool *sync.Pool
+	head bool
+
+	started bool
+	gz      *gzip.Writer
+}
+
+// start decides whether to compress the response, using the headers set so
+// far, `status`, and the first bytes of the body, `b`, and sends `status`.
+func (w *gzipResponseWriter) start(status int, b []byte) {
+	w.started = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(b) > 0 && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Type", http.DetectContentType(b))
+	}
+	switch {
+	case w.head, status < 200, status == http.StatusNoContent,
+		status == http.StatusNotModified, status == http.StatusPartialContent:
+	case header.Get("Content-Encoding") != "", header.Get("Content-Range") != "":
+	case !compressible(header.Get("Content-Type")):
+	default:
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = w.pool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// WriteHeader decides whether to compress the response and sends `status`,
+// if the response hasn't started yet. Informational statuses are passed on
+// without starting the response.
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.started {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.start(status, nil)
+}
+
+// Write writes `b` to the response, compressing it if the response is being
+// compressed, starting the response with a 200 status if it hasn't started
+// yet.
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.start(http.StatusOK, b)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush sends the data written so far to the client, compressing what's
+// been written and flushing the wrapped http.ResponseWriter.
+func (w *gzipResponseWriter) Flush() {
+	if !w.started {
+		w.start(http.StatusOK, nil)
+	}
+	if w.gz != nil {
+		w.gz.Flush() //nolint:errcheck
+	}
+	http.NewResponseController(w.ResponseWriter).Flush() //nolint:errcheck
+}
+
+// Unwrap returns the http.ResponseWriter being wrapped, for use with
+// http.ResponseController.
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close finishes compressing the response, if it was being compressed, and
+// returns the gzip.Writer to its pool.
+func (w *gzipResponseWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	w.gz.Close() //nolint:errcheck
+	w.gz.Reset(nil)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
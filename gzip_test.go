@@ -0,0 +1,135 @@
+package trout
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	type testCase struct {
+		url, acceptEncoding string
+		compressed          bool
+		contentType         string
+	}
+	body := strings.Repeat("hello, world\n", 100)
+	cases := []testCase{
+		{"/text", "gzip, deflate", true, "text/plain; charset=utf-8"},
+		{"/text", "br;q=1.0, *;q=0.5", true, "text/plain; charset=utf-8"},
+		{"/text", "gzip;q=0", false, "text/plain; charset=utf-8"},
+		{"/text", "", false, "text/plain; charset=utf-8"},
+		{"/image", "gzip", false, "image/png"},
+		{"/svg", "gzip", true, "image/svg+xml"},
+		{"/encoded", "gzip", false, "text/plain"},
+		{"/empty", "gzip", false, ""},
+	}
+	var router Router
+	router.SetMiddleware(Gzip(gzip.BestSpeed))
+	router.Endpoint("/text").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	router.Endpoint("/image").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(body))
+	})
+	router.Endpoint("/svg").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte(body))
+	})
+	router.Endpoint("/encoded").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte(body))
+	})
+	router.Endpoint("/empty").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		r.Header.Set("Accept-Encoding", c.acceptEncoding)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("Expected %s with %q to vary on Accept-Encoding, got %q", c.url, c.acceptEncoding, vary)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != c.contentType {
+			t.Errorf("Expected %s with %q to have a Content-Type of %q, got %q", c.url, c.acceptEncoding, c.contentType, ct)
+		}
+		if compressed := w.Header().Get("Content-Encoding") == "gzip"; compressed != c.compressed {
+			t.Errorf("Expected %s with %q to be compressed to be %v, got %v", c.url, c.acceptEncoding, c.compressed, compressed)
+		}
+		if c.url == "/empty" {
+			if w.Body.Len() != 0 {
+				t.Errorf("Expected %s to have no body, got %q", c.url, w.Body.String())
+			}
+			continue
+		}
+		got := w.Body.String()
+		if c.compressed {
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("Error reading compressed body of %s: %+v", c.url, err)
+			}
+			b, err := io.ReadAll(gz)
+			if err != nil {
+				t.Fatalf("Error decompressing body of %s: %+v", c.url, err)
+			}
+			got = string(b)
+		}
+		if got != body {
+			t.Errorf("Expected %s with %q to have the original body, got %q", c.url, c.acceptEncoding, got)
+		}
+	}
+}
+
+func TestGzipWithMetrics(t *testing.T) {
+	for _, gzipFirst := range []bool{true, false} {
+		var rec testRecorder
+		var router Router
+		if gzipFirst {
+			router.SetMiddleware(Gzip(gzip.DefaultCompression), Metrics(&rec))
+		} else {
+			router.SetMiddleware(Metrics(&rec), Gzip(gzip.DefaultCompression))
+		}
+		router.Endpoint("/teapot").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+			http.NewResponseController(w).Flush()
+		})
+		r, err := http.NewRequest("GET", "/teapot", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %+v", err)
+		}
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusTeapot || !w.Flushed {
+			t.Errorf("Expected a flushed 418, got %d, flushed: %v", w.Code, w.Flushed)
+		}
+		if len(rec) != 1 || rec[0].status != http.StatusTeapot {
+			t.Errorf("Expected a 418 to be observed, got %v", rec)
+		}
+		gz, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("Error reading compressed body: %+v", err)
+		}
+		if b, err := io.ReadAll(gz); err != nil || string(b) != "short and stout" {
+			t.Errorf("Expected the decompressed body to be %q, got %q (%v)", "short and stout", b, err)
+		}
+	}
+}
+
+func TestGzipInvalidLevel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected an invalid level to panic")
+		}
+	}()
+	Gzip(42)
+}
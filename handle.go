@@ -0,0 +1,57 @@
+package trout
+
+import "net/http"
+
+// Handler is an http.Handler that can return an error instead of writing
+// its own error response. Errors are passed to the ErrorHandler of the
+// Router that routed the request, or answered with a 500 if it has none, or
+// the request wasn't routed by a Router. A Handler that returns an error
+// shouldn't have written anything to the response.
+type Handler func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls `h`, and passes any error it returns to the ErrorHandler of
+// the Router that routed `r`.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := h(w, r)
+	if err == nil {
+		return
+	}
+	if rt := routeFromRequest(r); rt != nil && rt.errorHandler != nil {
+		rt.errorHandler(w, r, err)
+		return
+	}
+	defaultErrorHandler(w, r, err)
+}
+
+// defaultErrorHandler responds to requests whose Handler returned an error
+// when the Router has no ErrorHandler, without revealing the error.
+func defaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("500 Internal Server Error")) //nolint:errcheck
+}
+
+// Handle sets `h` as the default handler for `e`, exactly like Handler, with
+// any error it returns passed to the Router's ErrorHandler.
+//
+// Handle is not concurrency-safe, and should not be used while the Router
+// `e` belongs to is actively routing traffic.
+func (e *Endpoint) Handle(h Handler) *Endpoint {
+	return e.Handler(h)
+}
+
+// Handle sets `h` as the default handler for `p`, exactly like Handler, with
+// any error it returns passed to the Router's ErrorHandler.
+//
+// Handle is not concurrency-safe, and should not be used while the Router
+// `p` belongs to is actively routing traffic.
+func (p *Prefix) Handle(h Handler) *Prefix {
+	return p.Handler(h)
+}
+
+// Handle associates `h` with the Endpoint or Prefix associated with `m`,
+// exactly like Handler, with any error it returns passed to the Router's
+// ErrorHandler.
+func (m Methods) Handle(h Handler) {
+	m.Handler(h)
+}
@@ -0,0 +1,77 @@
+package trout
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var errNotYours = errors.New("not yours")
+
+func TestHandle(t *testing.T) {
+	type testCase struct {
+		method, url string
+		code        int
+		body        string
+	}
+	handle := func(w http.ResponseWriter, r *http.Request) error {
+		switch RequestVars(r).Get("id") {
+		case "secret":
+			return errNotYours
+		case "broken":
+			return errors.New("broken")
+		}
+		_, err := w.Write([]byte("post " + RequestVars(r).Get("id")))
+		return err
+	}
+	cases := []testCase{
+		{"GET", "/posts/1", http.StatusOK, "post 1"},
+		{"GET", "/posts/secret", http.StatusForbidden, "forbidden"},
+		{"GET", "/posts/broken", http.StatusInternalServerError, "broken"},
+		{"DELETE", "/posts/secret", http.StatusForbidden, "forbidden"},
+		{"GET", "/files/secret", http.StatusForbidden, "forbidden"},
+	}
+	router := NewRouter(WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, errNotYours) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("forbidden"))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}))
+	router.Endpoint("/posts/{id}").Handle(handle)
+	router.Endpoint("/posts/{id}").Methods("DELETE").Handle(handle)
+	router.Prefix("/files/{id}").Handle(handle)
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s %s to return %d, got %d", c.method, c.url, c.code, w.Code)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected %s %s to have a body of %q, got %q", c.method, c.url, c.body, body)
+		}
+	}
+}
+
+func TestHandleWithoutErrorHandler(t *testing.T) {
+	var router Router
+	router.Endpoint("/").Handle(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("secret details")
+	})
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "500 Internal Server Error" {
+		t.Errorf("Expected a 500 without the error's details, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,65 @@
+package trout
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headHandler wraps `h` so that any response body it writes is discarded, as
+// is appropriate for responding to a HEAD request with a GET handler.
+func headHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &headResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(hw, r)
+		hw.flush()
+	})
+}
+
+// headResponseWriter is an http.ResponseWriter that counts and discards any
+// response body written to it. The status code is held back until the
+// handler is finished, so that a Content-Length can be set from the number of
+// bytes discarded.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+	flushed     bool
+}
+
+// WriteHeader records the status code to send when the handler is finished.
+func (w *headResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = status
+	w.wroteHeader = true
+}
+
+// Write discards `b`, counting its length towards the Content-Length.
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.written += int64(len(b))
+	return len(b), nil
+}
+
+// Unwrap returns the http.ResponseWriter being wrapped, for use with
+// http.ResponseController.
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flush sends the held-back status code, setting a Content-Length first if
+// the handler didn't set one and wrote a body.
+func (w *headResponseWriter) flush() {
+	if w.flushed {
+		return
+	}
+	w.flushed = true
+	if w.written > 0 && w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.FormatInt(w.written, 10))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
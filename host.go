@@ -0,0 +1,248 @@
+package trout
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Host is a set of Endpoints and Prefixes on a Router that are only matched
+// for requests to hosts that match a host template. It is only valid to
+// instantiate a Host by calling `Router.Host`.
+type Host struct {
+	router *Router
+	labels []key
+}
+
+// Host returns a Host whose Endpoints and Prefixes will be defined on
+// `router`, and only matched for requests whose host matches `template`.
+// Host templates are made up of `.`-separated labels, which can be static or
+// parameters, like `{tenant}.example.com`. Parameters follow the same rules
+// as they do for Endpoints, though they can't be catch-alls, and their
+// values are available through RequestVars and OrderedVars like any other
+// parameter, before the parameters of the path.
+//
+// The host of a request is read from its Host header, without the port, and
+// is matched case-insensitively. When more than one host template matches a
+// request, the one whose labels are the best match wins, starting from the
+// last label: a static label beats a constrained parameter, which beats a
+// parameter, just like path elements. So `api.example.com` will be used over
+// `{tenant}.example.com` for requests to `api.example.com`. If none of the
+// routes for the matching host templates match a request, it falls back to
+// the routes that weren't defined through a Host.
+//
+// Calling Host with the same template more than once returns Hosts that
+// define routes in the same place. Host panics if `template` is not a valid
+// host template. Use AddHost to get an error instead.
+func (router *Router) Host(template string) *Host {
+	host, err := router.AddHost(template)
+	if err != nil {
+		panic(err)
+	}
+	return host
+}
+
+// AddHost returns a Host, exactly like Host, but returns an error instead of
+// panicking if `template` is not a valid host template.
+func (router *Router) AddHost(template string) (*Host, error) {
+	labels, err := hostKeysFromString(template)
+	if err != nil {
+		return nil, err
+	}
+	return &Host{router: router, labels: labels}, nil
+}
+
+// Endpoint defines a new Endpoint on the Router `h` belongs to, only matched
+// for requests to hosts that match the template of `h`, following the same
+// rules as Router.Endpoint. Endpoint.URL returns only the path of the
+// Endpoint, without the host.
+func (h *Host) Endpoint(e string) *Endpoint {
+	endpoint, err := h.AddEndpoint(e)
+	if err != nil {
+		panic(err)
+	}
+	return endpoint
+}
+
+// AddEndpoint defines a new Endpoint on the Router `h` belongs to, exactly
+// like Endpoint, but returns an error instead of panicking if the URL template
+// isn't valid.
+func (h *Host) AddEndpoint(e string) (*Endpoint, error) {
+	keys, err := keysFromString(e, h.router.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+	return (*Endpoint)(h.add(keys, len(e) > 1 && strings.HasSuffix(e, "/"))), nil
+}
+
+// Prefix defines a new Prefix on the Router `h` belongs to, only matched for
+// requests to hosts that match the template of `h`, following the same rules
+// as Router.Prefix.
+func (h *Host) Prefix(p string) *Prefix {
+	prefix, err := h.AddPrefix(p)
+	if err != nil {
+		panic(err)
+	}
+	return prefix
+}
+
+// AddPrefix defines a new Prefix on the Router `h` belongs to, exactly like
+// Prefix, but returns an error instead of panicking if the URL template isn't
+// valid.
+func (h *Host) AddPrefix(p string) (*Prefix, error) {
+	keys, err := keysFromString(p, h.router.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+	keys[len(keys)-1].prefix = true
+	return (*Prefix)(h.add(keys, false)), nil
+}
+
+// add inserts `keys` into the trie of the Router `h` belongs to, under the
+// root node for the host template of `h`, and returns the terminating node
+// for `keys`, like Router.add.
+func (h *Host) add(keys []key, trailingSlash bool) *node {
+	if h.router.trie == nil {
+		h.router.trie = newTrie()
+	}
+	n, created := h.router.trie.addHost(h.labels, keys)
+	if created {
+		n.trailingSlash = trailingSlash
+	}
+	return n
+}
+
+// hostKeysFromString parses the host template `in` and returns the keys
+// that represent it, one per label. An error is returned if `in` isn't a
+// valid host template: if it has an empty label, if any of its labels
+// wouldn't be a valid path element in a URL template, or if it uses a
+// catch-all parameter.
+func hostKeysFromString(in string) ([]key, error) {
+	in = strings.TrimSuffix(in, ".")
+	if in == "" {
+		return nil, fmt.Errorf("trout: empty host template")
+	}
+	labels := splitLabels(in)
+	keys := make([]key, 0, len(labels))
+	for _, label := range labels {
+		if label == "" {
+			return nil, fmt.Errorf("trout: empty label in host template %q", in)
+		}
+		if strings.Contains(label, "/") {
+			return nil, fmt.Errorf("trout: label %q of host template %q can't contain a /", label, in)
+		}
+		parsed, err := keysFromString(label, false)
+		if err != nil {
+			return nil, fmt.Errorf("trout: invalid label %q in host template %q: %w", label, in, err)
+		}
+		k := parsed[0]
+		if k.catchAll {
+			return nil, fmt.Errorf("trout: catch-all parameter %q can't be used in host template %q", k.value, in)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// splitLabels splits the host template `in` on each `.` that isn't inside a
+// parameter, so constraints can use them.
+func splitLabels(in string) []string {
+	var labels []string
+	var depth, start int
+	for i := 0; i < len(in); i++ {
+		switch in[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '.':
+			if depth == 0 {
+				labels = append(labels, in[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(labels, in[start:])
+}
+
+// requestHost returns the host `r` was made to, lowercased, without its port
+// or any trailing `.`.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// hostMatch is the root node for a host template that matched the host of a
+// request, along with the parameters the template captured.
+type hostMatch struct {
+	root   *node
+	labels []key
+	params []Param
+}
+
+// matchHosts returns the host templates in `hosts` that match `host`, which
+// should be lowercased, best match first, according to `compareHosts`.
+func matchHosts(hosts []*hostRoot, host string) []hostMatch {
+	if len(hosts) < 1 || host == "" {
+		return nil
+	}
+	labels := strings.Split(host, ".")
+	var matches []hostMatch
+	for _, h := range hosts {
+		if params, ok := matchLabels(h.labels, labels); ok {
+			matches = append(matches, hostMatch{root: h.root, labels: h.labels, params: params})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return compareHosts(matches[i].labels, matches[j].labels) > 0
+	})
+	return matches
+}
+
+// matchLabels returns whether the keys of a host template match each of
+// `labels`, and the parameters they captured if they do.
+func matchLabels(keys []key, labels []string) ([]Param, bool) {
+	if len(keys) != len(labels) {
+		return nil, false
+	}
+	var params []Param
+	for i, k := range keys {
+		if !k.dynamic {
+			if k.value != labels[i] {
+				return nil, false
+			}
+			continue
+		}
+		if !k.matches(labels[i]) {
+			return nil, false
+		}
+		val := labels[i]
+		if k.mixed() {
+			val, _ = k.trim(val)
+		}
+		params = append(params, Param{Name: k.value, Value: val})
+	}
+	return params, true
+}
+
+// compareHosts compares how good a match two host templates are for the same
+// host, returning a positive number if `a` is the better match, a negative
+// number if `b` is, and 0 if they're equally good. Labels are compared using
+// `weightKey`, starting from the last label, and the first difference
+// decides.
+func compareHosts(a, b []key) int {
+	for i := len(a) - 1; i >= 0 && i < len(b); i-- {
+		if diff := weightKey(a[i]) - weightKey(b[i]); diff != 0 {
+			return diff
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package trout
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	type testCase struct {
+		url, handler string
+		params       []Param
+	}
+	cases := []testCase{
+		{"http://acme.example.com/users/1", "tenant-user", []Param{{"tenant", "acme"}, {"id", "1"}}},
+		{"http://ACME.Example.com:8080/users/1", "tenant-user", []Param{{"tenant", "acme"}, {"id", "1"}}},
+		{"http://api.example.com/users/1", "api-user", []Param{{"id", "1"}}},
+		{"http://acme.example.com/status", "status", nil},
+		{"http://example.com/users/1", "user", []Param{{"id", "1"}}},
+		{"/users/1", "user", []Param{{"id", "1"}}},
+		{"http://v2.api.example.org/", "versioned", []Param{{"version", "2"}}},
+		{"http://vx.api.example.org/", "404", nil},
+	}
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/users/{id}").Handler(testHandler("user"))
+	router.Endpoint("/status").Handler(testHandler("status"))
+	router.Host("{tenant}.example.com").Endpoint("/users/{id}").Handler(testHandler("tenant-user"))
+	router.Host("api.example.com").Endpoint("/users/{id}").Handler(testHandler("api-user"))
+	router.Host("v{version:int}.api.example.org").Endpoint("/").Handler(testHandler("versioned"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, r := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+			continue
+		}
+		if params := OrderedVars(r); !reflect.DeepEqual(params, c.params) {
+			t.Errorf("Expected params for %s to be %v, got %v", c.url, c.params, params)
+		}
+	}
+
+	if m := router.Match("GET", "http://acme.example.com/users/1"); m.Pattern != "{tenant}.example.com/users/{id}" {
+		t.Errorf("Expected pattern to include the host template, got %q", m.Pattern)
+	}
+	if conflicts := router.Validate(); conflicts != nil {
+		t.Errorf("Expected routes on different hosts not to conflict, got %v", conflicts)
+	}
+	if routes := router.Routes(); len(routes) != 5 {
+		t.Errorf("Expected 5 routes, got %v", routes)
+	}
+}
+
+func TestInvalidHostTemplates(t *testing.T) {
+	invalid := []string{
+		"",
+		"example..com",
+		"{tenant.example.com",
+		"{rest...}.example.com",
+		"a/b.example.com",
+	}
+	for _, template := range invalid {
+		var router Router
+		if _, err := router.AddHost(template); err == nil {
+			t.Errorf("Expected an error adding host %q, got nil", template)
+		}
+	}
+}
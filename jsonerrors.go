@@ -0,0 +1,115 @@
+package trout
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// JSON404Handler responds with a 404 and a JSON body, like
+	// `{"error":"not found"}`, for Routers that serve APIs. It can be
+	// used as a Router's Handle404, or with NotFound.
+	JSON404Handler = http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONError(w, http.StatusNotFound, jsonError{Error: "not found"})
+	}))
+
+	// JSON405Handler responds with a 405 and a JSON body, like
+	// `{"error":"method not allowed","allowed":["GET","POST"]}`, for
+	// Routers that serve APIs. The allowed methods are read the same way
+	// the default 405 handler reads them, and are also set in the Allow
+	// header. It can be used as a Router's Handle405, or with
+	// MethodNotAllowed.
+	JSON405Handler = http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := sortMethods(methodsFromRequest(r))
+		w.Header().Set("Allow", joinMethods(allowed))
+		writeJSONError(w, http.StatusMethodNotAllowed, jsonMethodError{Error: "method not allowed", Allowed: allowed})
+	}))
+)
+
+// jsonError is the body written by JSON404Handler.
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// jsonMethodError is the body written by JSON405Handler.
+type jsonMethodError struct {
+	Error   string   `json:"error"`
+	Allowed []string `json:"allowed"`
+}
+
+// writeJSONError writes `body` to `w` as JSON, with the status code `code`.
+func writeJSONError(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body) //nolint:errcheck
+}
+
+// NegotiatedErrorHandler returns an http.Handler that responds with `status`
+// and a body in the format the request's Accept header prefers: JSON, like
+// JSON404Handler and JSON405Handler, if it prefers `application/json`, and
+// plain text, like the default handlers, otherwise. It can be used for both
+// a Router's Handle404 and Handle405; for a 405, the Allow header is always
+// set. Any other status gets a body made from http.StatusText.
+func NegotiatedErrorHandler(status int) http.Handler {
+	var jsonHandler, textHandler http.Handler
+	switch status {
+	case http.StatusNotFound:
+		jsonHandler, textHandler = JSON404Handler, default404Handler
+	case http.StatusMethodNotAllowed:
+		jsonHandler, textHandler = JSON405Handler, default405Handler
+	default:
+		text := strings.ToLower(http.StatusText(status))
+		jsonHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSONError(w, status, jsonError{Error: text})
+		})
+		textHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, "%d %s", status, http.StatusText(status)) //nolint:errcheck
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if prefersJSON(r.Header.Values("Accept")) {
+			jsonHandler.ServeHTTP(w, r)
+			return
+		}
+		textHandler.ServeHTTP(w, r)
+	})
+}
+
+// prefersJSON returns whether the media ranges in the Accept headers `accept`
+// give `application/json` a higher quality than plain text. A wildcard on
+// its own, or no Accept header at all, prefers plain text.
+func prefersJSON(accept []string) bool {
+	var jsonQ, textQ float64
+	for _, header := range accept {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType, params, _ := strings.Cut(mediaRange, ";")
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				name, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(name, "q") {
+					continue
+				}
+				if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+					q = parsed
+				}
+			}
+			switch strings.ToLower(strings.TrimSpace(mediaType)) {
+			case "application/json", "application/*":
+				if q > jsonQ {
+					jsonQ = q
+				}
+			case "text/plain", "text/*":
+				if q > textQ {
+					textQ = q
+				}
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ >= textQ
+}
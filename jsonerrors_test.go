@@ -0,0 +1,96 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONErrorHandlers(t *testing.T) {
+	type testCase struct {
+		url, method string
+		code        int
+		body, allow string
+	}
+	cases := []testCase{
+		{"/missing", "GET", http.StatusNotFound, `{"error":"not found"}`, ""},
+		{"/posts", "DELETE", http.StatusMethodNotAllowed, `{"error":"method not allowed","allowed":["GET","POST"]}`, "GET, POST"},
+	}
+	var router Router
+	router.Handle404 = JSON404Handler
+	router.Handle405 = JSON405Handler
+	router.Endpoint("/posts").POST(testHandler("create")).GET(testHandler("list"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s %s to return %d, got %d", c.method, c.url, c.code, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("Expected %s %s to have a JSON Content-Type, got %q", c.method, c.url, ct)
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != c.body {
+			t.Errorf("Expected %s %s to have a body of %s, got %s", c.method, c.url, c.body, body)
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("Expected %s %s to have an Allow header of %q, got %q", c.method, c.url, c.allow, allow)
+		}
+	}
+}
+
+func TestNegotiatedErrorHandler(t *testing.T) {
+	type testCase struct {
+		url, method, accept string
+		code                int
+		body, allow         string
+	}
+	cases := []testCase{
+		{"/missing", "GET", "", http.StatusNotFound, "404 Page Not Found", ""},
+		{"/missing", "GET", "*/*", http.StatusNotFound, "404 Page Not Found", ""},
+		{"/missing", "GET", "text/html, application/json;q=0.9", http.StatusNotFound, `{"error":"not found"}`, ""},
+		{"/missing", "GET", "application/json;q=0.5, text/plain", http.StatusNotFound, "404 Page Not Found", ""},
+		{"/missing", "GET", "application/json;q=0", http.StatusNotFound, "404 Page Not Found", ""},
+		{"/posts", "DELETE", "application/json", http.StatusMethodNotAllowed, `{"error":"method not allowed","allowed":["GET"]}`, "GET"},
+		{"/posts", "DELETE", "text/plain", http.StatusMethodNotAllowed, "405 Method Not Allowed", "GET"},
+	}
+	var router Router
+	router.Handle404 = NegotiatedErrorHandler(http.StatusNotFound)
+	router.Handle405 = NegotiatedErrorHandler(http.StatusMethodNotAllowed)
+	router.Endpoint("/posts").GET(testHandler("list"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s %s with Accept %q to return %d, got %d", c.method, c.url, c.accept, c.code, w.Code)
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != c.body {
+			t.Errorf("Expected %s %s with Accept %q to have a body of %s, got %s", c.method, c.url, c.accept, c.body, body)
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("Expected %s %s to have an Allow header of %q, got %q", c.method, c.url, c.allow, allow)
+		}
+	}
+
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	NegotiatedErrorHandler(http.StatusTooManyRequests).ServeHTTP(w, r)
+	if body := strings.TrimSpace(w.Body.String()); w.Code != http.StatusTooManyRequests || body != `{"error":"too many requests"}` {
+		t.Errorf("Expected a 429 with a JSON body, got %d %s", w.Code, body)
+	}
+}
@@ -0,0 +1,143 @@
+package trout
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// MatchKind describes the outcome of routing a request.
+type MatchKind int
+
+const (
+	// MatchNotFound means no Endpoint or Prefix matched the request, and
+	// a 404 would be served.
+	MatchNotFound MatchKind = iota
+	// MatchEndpoint means an Endpoint matched the request and has a
+	// handler for the request's method.
+	MatchEndpoint
+	// MatchPrefix means a Prefix matched the request and has a handler
+	// for the request's method.
+	MatchPrefix
+	// MatchMethodNotAllowed means an Endpoint or Prefix matched the
+	// request, but has no handler for the request's method, and a 405
+	// would be served.
+	MatchMethodNotAllowed
+)
+
+// String returns a human-readable description of `k`.
+func (k MatchKind) String() string {
+	switch k {
+	case MatchNotFound:
+		return "not found"
+	case MatchEndpoint:
+		return "endpoint"
+	case MatchPrefix:
+		return "prefix"
+	case MatchMethodNotAllowed:
+		return "method not allowed"
+	}
+	return "unknown"
+}
+
+// RouteMatch describes how a Router would route a request.
+type RouteMatch struct {
+	// Pattern is the URL template of the Endpoint or Prefix that was
+	// matched, as it would be set in the Trout-Pattern header. It is
+	// empty when Kind is MatchNotFound.
+	Pattern string
+	// Params holds the values for the parameters in Pattern, as they
+	// would be returned by RequestVars, though the keys are the names
+	// as they appear in Pattern, not http.CanonicalHeaderKey forms.
+	Params map[string][]string
+	// Methods holds the methods the matched Endpoint or Prefix has
+	// handlers for, as they would be set in the Trout-Methods header.
+	Methods []string
+	// Kind describes the outcome of routing the request.
+	Kind MatchKind
+}
+
+// Matched returns true if an Endpoint or Prefix matched the request and has
+// a handler for the request's method.
+func (m RouteMatch) Matched() bool {
+	return m.Kind == MatchEndpoint || m.Kind == MatchPrefix
+}
+
+// Match returns a description of how `router` would route a request made
+// using `method` for `path`, without serving the request. `path` may include
+// a query string. This is useful for testing and inspecting a Router. When
+// the Router's DisableMethodNotAllowed property is set, requests it would
+// answer with a 404 instead of a 405 are reported as MatchNotFound.
+func (router Router) Match(method, path string) RouteMatch {
+	u, err := url.Parse(path)
+	if err != nil {
+		return RouteMatch{Kind: MatchNotFound}
+	}
+	r := &http.Request{
+		Method: method,
+		URL:    u,
+		Header: http.Header{},
+		Host:   u.Host,
+	}
+	route := router.routeRequest(r)
+	if route == nil {
+		return RouteMatch{Kind: MatchNotFound}
+	}
+	res := RouteMatch{
+		Pattern: route.pattern,
+		Params:  paramsMap(route.orderedParams),
+		Methods: append([]string(nil), route.methods...),
+	}
+	switch {
+	case route.handler == nil && (len(route.methods) < 1 || router.DisableMethodNotAllowed):
+		return RouteMatch{Kind: MatchNotFound}
+	case route.handler == nil:
+		res.Kind = MatchMethodNotAllowed
+	case route.prefix:
+		res.Kind = MatchPrefix
+	default:
+		res.Kind = MatchEndpoint
+	}
+	return res
+}
+
+// AllowedMethods returns the methods that the Endpoints and Prefixes matching
+// `path` have handlers for, sorted and de-duplicated, without serving a
+// request. Unlike Match, which only describes the Endpoint or Prefix a
+// request would be routed to, the methods of every Endpoint and Prefix that
+// matches `path` are included, so it's the full set of methods that could be
+// used for `path`. `path` may include a query string, and may be an absolute
+// URL, whose host is used to match Hosts. Default handlers set using the
+// Handler method aren't included, as they don't say which methods they
+// serve, unless the Router's CatchAllMethods property is set. AllowedMethods
+// returns nil if nothing matches `path`.
+func (router Router) AllowedMethods(path string) []string {
+	if router.trie == nil {
+		return nil
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil
+	}
+	r := &http.Request{URL: u, Host: u.Host}
+	_, pieces := router.splitPath(r, nil)
+	nodes, _ := router.trie.findHostNodes(requestHost(r), pieces, router.fold(nil, pieces), router.SegmentMatcher)
+	query := &requestQuery{raw: u.RawQuery}
+	var methods []string
+	var matched bool
+	for _, n := range nodes {
+		if n == nil || n.terminator == nil {
+			continue
+		}
+		for _, term := range append([]*node{n.terminator}, n.terminator.queryVariants...) {
+			if !matchesQuery(term, query) {
+				continue
+			}
+			matched = true
+			methods = append(methods, terminatorMethods(term, router.CatchAllMethods)...)
+		}
+	}
+	if !matched {
+		return nil
+	}
+	return sortMethods(methods)
+}
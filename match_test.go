@@ -0,0 +1,87 @@
+package trout
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	type testCase struct {
+		method, path, pattern string
+		params                map[string][]string
+		kind                  MatchKind
+	}
+	cases := []testCase{
+		{"GET", "/posts/foo", "/posts/{slug}", map[string][]string{"slug": {"foo"}}, MatchEndpoint},
+		{"POST", "/posts/foo", "/posts/{slug}", map[string][]string{"slug": {"foo"}}, MatchMethodNotAllowed},
+		{"GET", "/static/css/site.css", "/static::prefix", map[string][]string{}, MatchPrefix},
+		{"GET", "/users/foo", "", nil, MatchNotFound},
+		{"GET", "/posts/foo?draft=true", "/posts/{slug}", map[string][]string{"slug": {"foo"}}, MatchEndpoint},
+	}
+	var router Router
+	router.Endpoint("/posts/{slug}").Methods("GET").Handler(testHandler("post"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	for _, c := range cases {
+		m := router.Match(c.method, c.path)
+		if m.Kind != c.kind {
+			t.Errorf("Expected %s %s to be %s, got %s", c.method, c.path, c.kind, m.Kind)
+		}
+		if m.Matched() != (c.kind == MatchEndpoint || c.kind == MatchPrefix) {
+			t.Errorf("Expected %s %s Matched to be consistent with %s", c.method, c.path, m.Kind)
+		}
+		if m.Pattern != c.pattern {
+			t.Errorf("Expected %s %s to match %q, got %q", c.method, c.path, c.pattern, m.Pattern)
+		}
+		if len(m.Params) != len(c.params) {
+			t.Errorf("Expected %s %s to have params %v, got %v", c.method, c.path, c.params, m.Params)
+		}
+		for k, v := range c.params {
+			if strings.Join(m.Params[k], ",") != strings.Join(v, ",") {
+				t.Errorf("Expected %s %s to have %s of %v, got %v", c.method, c.path, k, v, m.Params[k])
+			}
+		}
+	}
+
+	var empty Router
+	if m := empty.Match("GET", "/"); m.Kind != MatchNotFound {
+		t.Errorf("Expected an empty router to not match, got %s", m.Kind)
+	}
+}
+
+func TestMatchDisableMethodNotAllowed(t *testing.T) {
+	router := NewRouter(WithDisableMethodNotAllowed())
+	router.Endpoint("/posts/{slug}").Methods("GET").Handler(testHandler("post"))
+	m := router.Match("POST", "/posts/foo")
+	if m.Kind != MatchNotFound || m.Pattern != "" || len(m.Methods) != 0 {
+		t.Errorf("Expected POST /posts/foo not to be found, got %+v", m)
+	}
+	if m := router.Match("GET", "/posts/foo"); m.Kind != MatchEndpoint {
+		t.Errorf("Expected GET /posts/foo to match an Endpoint, got %s", m.Kind)
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	type testCase struct {
+		path    string
+		methods []string
+	}
+	cases := []testCase{
+		{"/posts", []string{"GET", "POST"}},
+		{"/posts/foo", []string{"DELETE", "GET", "PUT"}},
+		{"/posts/foo?draft", []string{"DELETE", "GET", "PATCH", "PUT"}},
+		{"/static/css/site.css", []string{}},
+		{"/missing", nil},
+	}
+	var router Router
+	router.Endpoint("/posts").Methods("GET", "POST").Handler(testHandler("posts"))
+	router.Endpoint("/posts/{slug}").Methods("GET", "PUT").Handler(testHandler("post"))
+	router.Endpoint("/posts/{slug}").Query("draft", "").PATCH(testHandler("draft"))
+	router.Endpoint("/{kind}/{id}").DELETE(testHandler("delete"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	for _, c := range cases {
+		if methods := router.AllowedMethods(c.path); !reflect.DeepEqual(methods, c.methods) {
+			t.Errorf("Expected methods for %s to be %v, got %v", c.path, c.methods, methods)
+		}
+	}
+}
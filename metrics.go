@@ -0,0 +1,37 @@
+package trout
+
+import (
+	"net/http"
+	"time"
+)
+
+// MetricsRecorder records metrics about the requests served by a Router. It
+// can be implemented using any metrics library, such as by incrementing a
+// Prometheus counter and observing a histogram labelled with the method,
+// pattern, and status, without trout depending on that library.
+type MetricsRecorder interface {
+	// ObserveRequest is called once for each request, after it has
+	// been served, with the request's method, the pattern of the
+	// Endpoint or Prefix it matched, the status code of the response,
+	// and how long it took to serve.
+	ObserveRequest(method, pattern string, status int, duration time.Duration)
+}
+
+// Metrics returns middleware that reports each request to `rec` once it has
+// been served. Like AccessLog, it reports the pattern the request matched
+// instead of its path, so metrics labelled with it have a low cardinality.
+//
+// Metrics should be used as Router middleware, set using
+// Router.SetMiddleware, so that it runs after the request has been routed and
+// can see the pattern it matched. Requests that didn't match an Endpoint or
+// Prefix are reported with an empty pattern.
+func Metrics(rec MetricsRecorder) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := newStatusResponseWriter(w)
+			h.ServeHTTP(sw, r)
+			rec.ObserveRequest(r.Method, Pattern(r), sw.Status(), time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	method, pattern string
+	status          int
+}
+
+type testRecorder []observation
+
+func (rec *testRecorder) ObserveRequest(method, pattern string, status int, duration time.Duration) {
+	*rec = append(*rec, observation{method: method, pattern: pattern, status: status})
+}
+
+func TestMetrics(t *testing.T) {
+	cases := []observation{
+		{"GET", "/posts/{slug}", http.StatusTeapot},
+		{"POST", "/posts/{slug}", http.StatusMethodNotAllowed},
+		{"GET", "/static::prefix", http.StatusOK},
+		{"GET", "", http.StatusNotFound},
+	}
+	urls := []string{"/posts/foo", "/posts/bar", "/static/site.css", "/missing"}
+	var rec testRecorder
+	var router Router
+	router.SetMiddleware(Metrics(&rec))
+	router.Endpoint("/posts/{slug}").GET(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	router.Prefix("/static").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	})
+	for i, c := range cases {
+		r, err := http.NewRequest(c.method, urls[i], nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, urls[i], err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if len(rec) != i+1 {
+			t.Fatalf("Expected %d observations, got %v", i+1, rec)
+		}
+		if rec[i] != c {
+			t.Errorf("Expected %s %s to be observed as %+v, got %+v", c.method, urls[i], c, rec[i])
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package trout
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// openAPIMethods are the methods an OpenAPI Path Item can describe, in the
+// order they're listed in the specification.
+var openAPIMethods = []string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodHead,
+	http.MethodPatch,
+	http.MethodTrace,
+}
+
+// PathItem describes an Endpoint or Prefix as an OpenAPI path. It encodes to
+// JSON as an OpenAPI Path Item Object, with an operation for each method and
+// the path's parameters, so the map returned by Router.OpenAPIPaths can be
+// used as the `paths` of an OpenAPI document.
+type PathItem struct {
+	// Methods holds the methods the Endpoint or Prefix has handlers for,
+	// sorted, like RouteInfo.Methods. A default handler set using the
+	// Handler method is listed as "*", and is described as an operation
+	// for every method OpenAPI supports that doesn't have its own
+	// handler.
+	Methods []string
+	// Parameters holds the parameters of the path, in the order they
+	// appear in it.
+	Parameters []PathParameter
+}
+
+// PathParameter describes a parameter of a PathItem.
+type PathParameter struct {
+	// Name is the name of the parameter, as it appears in the path.
+	Name string
+	// Pattern is the regular expression the parameter is constrained
+	// with, if any, as it would appear in an OpenAPI schema.
+	Pattern string
+	// Enum holds the values the parameter is constrained to, if it was
+	// constrained to a list of values.
+	Enum []string
+}
+
+// MarshalJSON encodes `p` as an OpenAPI Path Item Object.
+func (p PathItem) MarshalJSON() ([]byte, error) {
+	type schema struct {
+		Type    string   `json:"type"`
+		Pattern string   `json:"pattern,omitempty"`
+		Enum    []string `json:"enum,omitempty"`
+	}
+	type parameter struct {
+		Name     string `json:"name"`
+		In       string `json:"in"`
+		Required bool   `json:"required"`
+		Schema   schema `json:"schema"`
+	}
+	type response struct {
+		Description string `json:"description"`
+	}
+	type operation struct {
+		Responses map[string]response `json:"responses"`
+	}
+
+	item := map[string]interface{}{}
+	if len(p.Parameters) > 0 {
+		params := make([]parameter, 0, len(p.Parameters))
+		for _, param := range p.Parameters {
+			params = append(params, parameter{
+				Name:     param.Name,
+				In:       "path",
+				Required: true,
+				Schema:   schema{Type: "string", Pattern: param.Pattern, Enum: param.Enum},
+			})
+		}
+		item["parameters"] = params
+	}
+	op := operation{Responses: map[string]response{"default": {Description: "default response"}}}
+	for _, method := range p.Methods {
+		if method == catchAllMethod {
+			for _, m := range openAPIMethods {
+				if _, ok := item[strings.ToLower(m)]; !ok {
+					item[strings.ToLower(m)] = op
+				}
+			}
+			continue
+		}
+		item[strings.ToLower(method)] = op
+	}
+	return json.Marshal(item)
+}
+
+// OpenAPIPaths returns a PathItem for every Endpoint and Prefix registered on
+// `router`, keyed by their URL templates converted to OpenAPI path templates.
+// Any prefix set with SetPrefix is included. Constraints are removed from
+// parameters and described in their PathParameter instead, catch-all
+// parameters lose their `...`, and Prefixes get a trailing `{remainder}`
+// parameter, as OpenAPI has no way to describe a parameter spanning more than
+// one path element. Endpoints and Prefixes defined through a Host aren't
+// included, as OpenAPI describes hosts separately from paths.
+func (router Router) OpenAPIPaths() map[string]PathItem {
+	if router.trie == nil {
+		return nil
+	}
+	router.trie.RLock()
+	defer router.trie.RUnlock()
+
+	paths := map[string]PathItem{}
+	walkTerminators(router.trie.root, func(n *node) {
+		path, params := openAPIPath(n)
+		path = strings.TrimSuffix(router.prefix, "/") + path
+		// Endpoints created using Endpoint.Query share a path with the
+		// Endpoint they were created from, so their methods are merged
+		methods := paths[path].Methods
+		for method := range n.methods {
+			if !containsMethod(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+		sort.Strings(methods)
+		paths[path] = PathItem{
+			Methods:    methods,
+			Parameters: params,
+		}
+	})
+	return paths
+}
+
+// containsMethod returns true if `method` is one of `methods`.
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath returns the OpenAPI path template for the terminator `n`, and
+// the parameters in it. Parameters used more than once are only returned the
+// first time they appear, as OpenAPI requires parameter names to be unique.
+func openAPIPath(n *node) (string, []PathParameter) {
+	var keys []key
+	for a := n.parent; a != nil && a.parent != nil; a = a.parent {
+		keys = append(keys, a.value)
+	}
+	var b strings.Builder
+	var params []PathParameter
+	seen := map[string]bool{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		k := keys[i]
+		if !k.dynamic {
+			b.WriteString("/" + k.value)
+		} else {
+			b.WriteString("/" + k.before + "{" + k.value + "}" + k.after)
+			if !seen[k.value] {
+				seen[k.value] = true
+				param := PathParameter{Name: k.value, Enum: k.enum}
+				if k.re != nil {
+					param.Pattern = k.re.String()
+				}
+				params = append(params, param)
+			}
+		}
+		if k.prefix {
+			b.WriteString("/{remainder}")
+			params = append(params, PathParameter{Name: "remainder"})
+		}
+	}
+	if b.Len() < 1 {
+		return "/", params
+	}
+	return b.String(), params
+}
@@ -0,0 +1,70 @@
+package trout
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPIPaths(t *testing.T) {
+	var router Router
+	router.SetPrefix("/api")
+	router.Endpoint("/posts/{id:int}").Methods("GET", "DELETE").Handler(testHandler("post"))
+	router.Endpoint("/posts/{id:int}").Query("draft", "true").Methods("GET", "PUT").Handler(testHandler("draft"))
+	router.Endpoint("/orders/{status:open|closed}").GET(testHandler("orders"))
+	router.Endpoint("/files/{path...}").GET(testHandler("files"))
+	router.Prefix("/static/{version}").Handler(testHandler("static"))
+	router.Host("api.example.com").Endpoint("/hidden").GET(testHandler("hidden"))
+
+	expected := map[string]PathItem{
+		"/api/posts/{id}": {
+			Methods:    []string{"DELETE", "GET", "PUT"},
+			Parameters: []PathParameter{{Name: "id", Pattern: "^(?:-?[0-9]+)$"}},
+		},
+		"/api/orders/{status}": {
+			Methods:    []string{"GET"},
+			Parameters: []PathParameter{{Name: "status", Enum: []string{"open", "closed"}}},
+		},
+		"/api/files/{path}": {
+			Methods:    []string{"GET"},
+			Parameters: []PathParameter{{Name: "path"}},
+		},
+		"/api/static/{version}/{remainder}": {
+			Methods:    []string{"*"},
+			Parameters: []PathParameter{{Name: "version"}, {Name: "remainder"}},
+		},
+	}
+	paths := router.OpenAPIPaths()
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %+v, got %+v", expected, paths)
+	}
+
+	encoded, err := json.Marshal(paths["/api/posts/{id}"])
+	if err != nil {
+		t.Fatalf("Unexpected error encoding path item: %+v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unexpected error decoding path item: %+v", err)
+	}
+	for _, op := range []string{"get", "delete", "parameters"} {
+		if _, ok := decoded[op]; !ok {
+			t.Errorf("Expected %s in encoded path item, got %s", op, encoded)
+		}
+	}
+	if _, ok := decoded["post"]; ok {
+		t.Errorf("Expected no post operation in encoded path item, got %s", encoded)
+	}
+
+	encoded, err = json.Marshal(paths["/api/static/{version}/{remainder}"])
+	if err != nil {
+		t.Fatalf("Unexpected error encoding path item: %+v", err)
+	}
+	decoded = nil
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unexpected error decoding path item: %+v", err)
+	}
+	if len(decoded) != len(openAPIMethods)+1 {
+		t.Errorf("Expected a default handler to be described for every method, got %s", encoded)
+	}
+}
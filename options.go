@@ -0,0 +1,149 @@
+package trout
+
+import "net/http"
+
+// Option configures a Router created by NewRouter.
+type Option func(*Router)
+
+// NewRouter returns a Router configured by `opts`, which are applied in
+// order. The Router is ready to have Endpoints and Prefixes defined on it and
+// to serve requests, and can be shared as a pointer without the caveats that
+// apply to copying an empty Router. Its properties and setters can still be
+// used for further configuration.
+func NewRouter(opts ...Option) *Router {
+	router := &Router{trie: newTrie()}
+	for _, opt := range opts {
+		opt(router)
+	}
+	return router
+}
+
+// WithPrefix sets the prefix of the Router, like Router.SetPrefix.
+func WithPrefix(prefix string) Option {
+	return func(router *Router) {
+		router.SetPrefix(prefix)
+	}
+}
+
+// WithMiddleware adds middleware to the Router, like Router.AddMiddleware.
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(router *Router) {
+		router.AddMiddleware(mw...)
+	}
+}
+
+// WithPreMiddleware sets the pre-middleware of the Router, like
+// Router.SetPreMiddleware.
+func WithPreMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(router *Router) {
+		router.SetPreMiddleware(mw...)
+	}
+}
+
+// WithNotFound sets the Router's Handle404 property to `h`.
+func WithNotFound(h http.Handler) Option {
+	return func(router *Router) {
+		router.Handle404 = h
+	}
+}
+
+// WithMethodNotAllowed sets the Router's Handle405 property to `h`.
+func WithMethodNotAllowed(h http.Handler) Option {
+	return func(router *Router) {
+		router.Handle405 = h
+	}
+}
+
+// WithErrorHandler sets the Router's ErrorHandler property to `fn`.
+func WithErrorHandler(fn func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(router *Router) {
+		router.ErrorHandler = fn
+	}
+}
+
+// WithMaxPathSegments sets the Router's MaxPathSegments property to `limit`.
+func WithMaxPathSegments(limit int) Option {
+	return func(router *Router) {
+		router.MaxPathSegments = limit
+	}
+}
+
+// WithTiming sets the Router's Timing property to true.
+func WithTiming() Option {
+	return func(router *Router) {
+		router.Timing = true
+	}
+}
+
+// WithCaseSensitive sets the Router's CaseSensitive property to true.
+func WithCaseSensitive() Option {
+	return func(router *Router) {
+		router.CaseSensitive = true
+	}
+}
+
+// WithSegmentMatcher sets the Router's SegmentMatcher property to `match`.
+func WithSegmentMatcher(match func(key, piece string) bool) Option {
+	return func(router *Router) {
+		router.SegmentMatcher = match
+	}
+}
+
+// WithOnMatch sets the Router's OnMatch property to `fn`.
+func WithOnMatch(fn func(r *http.Request, pattern string, matched bool, status int)) Option {
+	return func(router *Router) {
+		router.OnMatch = fn
+	}
+}
+
+// WithCleanPath sets the Router's CleanPath property to true.
+func WithCleanPath() Option {
+	return func(router *Router) {
+		router.CleanPath = true
+	}
+}
+
+// WithRedirectTrailingSlash sets the Router's RedirectTrailingSlash property
+// to true.
+func WithRedirectTrailingSlash() Option {
+	return func(router *Router) {
+		router.RedirectTrailingSlash = true
+	}
+}
+
+// WithHandleOPTIONS sets the Router's HandleOPTIONS property to true.
+func WithHandleOPTIONS() Option {
+	return func(router *Router) {
+		router.HandleOPTIONS = true
+	}
+}
+
+// WithCatchAllMethods sets the Router's CatchAllMethods property to
+// `methods`.
+func WithCatchAllMethods(methods ...string) Option {
+	return func(router *Router) {
+		router.CatchAllMethods = methods
+	}
+}
+
+// WithHandleHEAD sets the Router's HandleHEAD property to true.
+func WithHandleHEAD() Option {
+	return func(router *Router) {
+		router.HandleHEAD = true
+	}
+}
+
+// WithDisableMethodNotAllowed sets the Router's DisableMethodNotAllowed
+// property to true.
+func WithDisableMethodNotAllowed() Option {
+	return func(router *Router) {
+		router.DisableMethodNotAllowed = true
+	}
+}
+
+// WithDisableHeaders sets the Router's DisableHeaders property to true.
+func WithDisableHeaders() Option {
+	return func(router *Router) {
+		router.DisableHeaders = true
+	}
+}
@@ -0,0 +1,56 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewRouter(t *testing.T) {
+	mw := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Middleware", "ran")
+			h.ServeHTTP(w, r)
+		})
+	}
+	router := NewRouter(
+		WithPrefix("/api"),
+		WithMiddleware(mw),
+		WithNotFound(testHandler("404")),
+		WithMethodNotAllowed(testHandler("405")),
+		WithTiming(),
+		WithCaseSensitive(),
+		WithCleanPath(),
+	)
+	if router.trie == nil {
+		t.Fatalf("Expected NewRouter to create the trie")
+	}
+	if !router.Timing || !router.CaseSensitive || !router.CleanPath {
+		t.Errorf("Expected options to set properties, got %+v", router)
+	}
+	router.Endpoint("/Posts").GET(testHandler("posts"))
+
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/api/Posts", "posts"},
+		{"GET", "/api//Posts", "posts"},
+		{"GET", "/api/posts", "404"},
+		{"POST", "/api/Posts", "405"},
+	}
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if body := w.Body.String(); body != c.handler {
+			t.Errorf("Expected %s %s to be served by %s, got %s", c.method, c.url, c.handler, body)
+		}
+		if w.Header().Get("Middleware") != "ran" {
+			t.Errorf("Expected middleware to run for %s %s", c.method, c.url)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package trout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// ErrMissingParam is returned, wrapped, when a parameter that was asked for
+// wasn't set for the request. It can be detected using errors.Is.
+var ErrMissingParam = errors.New("trout: missing parameter")
+
+// uuidRE matches the same UUIDs as the `uuid` constraint shortcut.
+var uuidRE = regexp.MustCompile("^(?:" + constraintShortcuts["uuid"] + ")$")
+
+// Params holds the parameters of the Endpoint or Prefix that matched a
+// request, with methods for parsing them. Its keys use
+// http.CanonicalHeaderKey, like the http.Header returned by RequestVars.
+type Params http.Header
+
+// RequestParams returns the parameters of the Endpoint or Prefix that matched
+// `r`, as returned by RequestVars, as Params.
+func RequestParams(r *http.Request) Params {
+	return Params(RequestVars(r))
+}
+
+// String returns the first value of the parameter `name`. If the parameter
+// wasn't set, an error wrapping ErrMissingParam is returned.
+func (p Params) String(name string) (string, error) {
+	vals := p[http.CanonicalHeaderKey(name)]
+	if len(vals) < 1 {
+		return "", fmt.Errorf("%w %q", ErrMissingParam, name)
+	}
+	return vals[0], nil
+}
+
+// Int returns the first value of the parameter `name`, parsed as a base 10
+// integer. If the parameter wasn't set, an error wrapping ErrMissingParam is
+// returned.
+func (p Params) Int(name string) (int64, error) {
+	val, err := p.String(name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("trout: parameter %q is not an integer: %w", name, err)
+	}
+	return i, nil
+}
+
+// Bool returns the first value of the parameter `name`, parsed using
+// strconv.ParseBool. If the parameter wasn't set, an error wrapping
+// ErrMissingParam is returned.
+func (p Params) Bool(name string) (bool, error) {
+	val, err := p.String(name)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("trout: parameter %q is not a boolean: %w", name, err)
+	}
+	return b, nil
+}
+
+// UUID returns the first value of the parameter `name`, after checking that
+// it's a UUID in the form matched by the `uuid` constraint shortcut. If the
+// parameter wasn't set, an error wrapping ErrMissingParam is returned.
+func (p Params) UUID(name string) (string, error) {
+	val, err := p.String(name)
+	if err != nil {
+		return "", err
+	}
+	if !uuidRE.MatchString(val) {
+		return "", fmt.Errorf("trout: parameter %q is not a UUID: %q", name, val)
+	}
+	return val, nil
+}
+
+// ParamInt returns the parameter `name` of the Endpoint or Prefix that
+// matched `r`, parsed as a base 10 integer. See Params.Int.
+func ParamInt(r *http.Request, name string) (int64, error) {
+	return RequestParams(r).Int(name)
+}
+
+// ParamBool returns the parameter `name` of the Endpoint or Prefix that
+// matched `r`, parsed using strconv.ParseBool. See Params.Bool.
+func ParamBool(r *http.Request, name string) (bool, error) {
+	return RequestParams(r).Bool(name)
+}
@@ -0,0 +1,55 @@
+package trout
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	var router Router
+	router.Endpoint("/users/{id}/flags/{flag}/sessions/{session}").Handler(testHandler("sessions"))
+	r, err := http.NewRequest("GET", "/users/123/flags/true/sessions/0b7e4a3c-5d1f-4e2a-9c8b-1f2e3d4c5b6a", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	_, r = router.getHandler(r)
+
+	if id, err := ParamInt(r, "id"); err != nil || id != 123 {
+		t.Errorf("Expected id to be 123, got %d (%v)", id, err)
+	}
+	if flag, err := ParamBool(r, "flag"); err != nil || !flag {
+		t.Errorf("Expected flag to be true, got %v (%v)", flag, err)
+	}
+	params := RequestParams(r)
+	if session, err := params.UUID("session"); err != nil || session != "0b7e4a3c-5d1f-4e2a-9c8b-1f2e3d4c5b6a" {
+		t.Errorf("Expected session to be a UUID, got %q (%v)", session, err)
+	}
+	if id, err := params.String("id"); err != nil || id != "123" {
+		t.Errorf("Expected id to be \"123\", got %q (%v)", id, err)
+	}
+
+	if _, err := params.Int("flag"); err == nil || errors.Is(err, ErrMissingParam) {
+		t.Errorf("Expected a parse error for flag, got %v", err)
+	}
+	if _, err := params.Bool("id"); err == nil || errors.Is(err, ErrMissingParam) {
+		t.Errorf("Expected a parse error for id, got %v", err)
+	}
+	if _, err := params.UUID("id"); err == nil || errors.Is(err, ErrMissingParam) {
+		t.Errorf("Expected an error for id, got %v", err)
+	}
+	for _, name := range []string{"missing", "Missing"} {
+		if _, err := params.String(name); !errors.Is(err, ErrMissingParam) {
+			t.Errorf("Expected ErrMissingParam from String, got %v", err)
+		}
+		if _, err := ParamInt(r, name); !errors.Is(err, ErrMissingParam) {
+			t.Errorf("Expected ErrMissingParam from ParamInt, got %v", err)
+		}
+		if _, err := ParamBool(r, name); !errors.Is(err, ErrMissingParam) {
+			t.Errorf("Expected ErrMissingParam from ParamBool, got %v", err)
+		}
+		if _, err := params.UUID(name); !errors.Is(err, ErrMissingParam) {
+			t.Errorf("Expected ErrMissingParam from UUID, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package trout
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// queryMatch is a query parameter that a terminator requires requests to
+// have. An empty value means the parameter only has to be present.
+type queryMatch struct {
+	key, value string
+}
+
+// String returns `q` the way it would be written in a query string.
+func (q queryMatch) String() string {
+	if q.value == "" {
+		return url.QueryEscape(q.key)
+	}
+	return url.QueryEscape(q.key) + "=" + url.QueryEscape(q.value)
+}
+
+// Query returns an Endpoint with the same URL template as `e` that only
+// matches requests whose query string sets the parameter `key` to `value`,
+// as well as any query parameters `e` already requires. If `value` is empty,
+// the parameter only has to be present, with any value. Keys and values are
+// case-sensitive.
+//
+// When more than one of the Endpoints for a URL template match a request,
+// the one that requires the most query parameters is used, after the usual
+// checks for methods and Priority. Requests that none of the Endpoints
+// returned by Query match fall back to `e`.
+//
+// The returned Endpoint starts out with no handlers and the same Priority as
+// `e`. Calling Query with the same parameters again returns the same
+// Endpoint.
+//
+// Query is not concurrency-safe, and should not be used while the Router `e`
+// belongs to is actively routing traffic.
+func (e *Endpoint) Query(key, value string) *Endpoint {
+	n := (*node)(e)
+	match := queryMatch{key: key, value: value}
+	for _, existing := range n.query {
+		if existing == match {
+			return e
+		}
+	}
+	query := make([]queryMatch, 0, len(n.query)+1)
+	query = append(query, n.query...)
+	query = append(query, match)
+	sort.Slice(query, func(i, j int) bool {
+		if query[i].key != query[j].key {
+			return query[i].key < query[j].key
+		}
+		return query[i].value < query[j].value
+	})
+
+	base := n
+	if n.queryBase != nil {
+		base = n.queryBase
+	}
+	for _, variant := range base.queryVariants {
+		if equalQuery(variant.query, query) {
+			return (*Endpoint)(variant)
+		}
+	}
+	variant := &node{
+		value:           base.value,
+		term:            base.term,
+		depth:           base.depth,
+		parent:          base.parent,
+		children:        map[string]*node{},
+		methods:         map[string]http.Handler{},
+		middleware:      map[string][]func(http.Handler) http.Handler{},
+		templatePath:    base.templatePath,
+		trailingSlash:   base.trailingSlash,
+		groupMiddleware: base.groupMiddleware,
+		priority:        n.priority,
+		query:           query,
+		queryBase:       base,
+	}
+	base.queryVariants = append(base.queryVariants, variant)
+	return (*Endpoint)(variant)
+}
+
+// equalQuery returns whether `a` and `b`, which must both be sorted, require
+// the same query parameters.
+func equalQuery(a, b []queryMatch) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// queryString returns the query parameters `n` requires, the way they'd be
+// written in a query string.
+func queryString(n *node) string {
+	pieces := make([]string, 0, len(n.query))
+	for _, q := range n.query {
+		pieces = append(pieces, q.String())
+	}
+	return strings.Join(pieces, "&")
+}
+
+// requestQuery holds the query string of a request, and parses it the first
+// time it's needed, so requests for routes that don't require any query
+// parameters never pay for parsing it.
+type requestQuery struct {
+	raw    string
+	values url.Values
+	parsed bool
+}
+
+// get returns the parsed query string of `q`.
+func (q *requestQuery) get() url.Values {
+	if q == nil {
+		return nil
+	}
+	if !q.parsed {
+		q.values, _ = url.ParseQuery(q.raw)
+		q.parsed = true
+	}
+	return q.values
+}
+
+// matchesQuery returns whether the query string in `q` has every query
+// parameter the terminator `n` requires.
+func matchesQuery(n *node, q *requestQuery) bool {
+	if len(n.query) < 1 {
+		return true
+	}
+	values := q.get()
+	for _, match := range n.query {
+		vals, ok := values[match.key]
+		if !ok {
+			return false
+		}
+		if match.value == "" {
+			continue
+		}
+		var found bool
+		for _, val := range vals {
+			if val == match.value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
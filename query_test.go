@@ -0,0 +1,55 @@
+package trout
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEndpointQuery(t *testing.T) {
+	type testCase struct {
+		url, method, handler string
+	}
+	cases := []testCase{
+		{"/search", "GET", "search"},
+		{"/search?type=video", "GET", "search"},
+		{"/search?type=image", "GET", "images"},
+		{"/search?type=video&type=image", "GET", "images"},
+		{"/search?type=image&size", "GET", "sized-images"},
+		{"/search?type=image&size=large", "GET", "sized-images"},
+		{"/search?size=large", "GET", "search"},
+		{"/search?debug", "GET", "debug"},
+		{"/search?debug", "POST", "search-post"},
+		{"/search?type=IMAGE", "GET", "search"},
+	}
+	var router Router
+	search := router.Endpoint("/search").Handler(testHandler("search"))
+	search.Methods("POST").Handler(testHandler("search-post"))
+	images := search.Query("type", "image").Handler(testHandler("images"))
+	images.Query("size", "").Handler(testHandler("sized-images"))
+	search.Query("debug", "").GET(testHandler("debug"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s %s to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+
+	if again := search.Query("type", "image"); again != images {
+		t.Errorf("Expected Query with the same parameters to return the same Endpoint")
+	}
+	if sized := search.Query("size", "").Query("type", "image"); sized != images.Query("size", "") {
+		t.Errorf("Expected Query to return the same Endpoint regardless of the order parameters were added in")
+	}
+
+	candidates := router.Explain("GET", "/search?type=image")
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %v", candidates)
+	}
+	if candidates[1].Query != "type=image" || !candidates[1].Selected {
+		t.Errorf("Expected the type=image Endpoint to be selected, got %v", candidates)
+	}
+}
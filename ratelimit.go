@@ -0,0 +1,155 @@
+package trout
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitOption configures the middleware returned by RateLimit.
+type RateLimitOption func(*rateLimiter)
+
+// RateLimitByClientIP makes the middleware returned by RateLimit keep a
+// separate limit for each client IP address on each pattern, read from the
+// request's RemoteAddr, so one noisy client can't use up the limit of an
+// Endpoint or Prefix for every other client. If the Router runs behind a
+// proxy, RemoteAddr should be set to the client's address before the request
+// reaches it.
+func RateLimitByClientIP() RateLimitOption {
+	return func(l *rateLimiter) {
+		l.byClientIP = true
+	}
+}
+
+// RateLimit returns middleware that limits the number of requests each
+// pattern serves to `limit` requests per second on average, allowing bursts
+// of up to `burst` requests. Each pattern has its own limit, so noisy
+// Endpoints and Prefixes don't starve the others. Requests over the limit get
+// a 429 response, with a Retry-After header saying how many seconds to wait
+// before trying again. A `limit` of 0 allows `burst` requests and no more,
+// though the limit for a pattern or client that hasn't made a request for
+// an hour may be forgotten, so it gets its burst back.
+//
+// RateLimit should be used as middleware on an Endpoint or Prefix, set using
+// Methods.Middleware, or as Router middleware, set using
+// Router.SetMiddleware, so that it runs after the request has been routed and
+// can see the pattern it matched. Requests that didn't match an Endpoint or
+// Prefix share a limit.
+func RateLimit(limit float64, burst int, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	l := &rateLimiter{
+		limit:   limit,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := l.allow(l.key(r), time.Now())
+			if !ok {
+				if l.limit > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				}
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte("429 Too Many Requests")) //nolint:errcheck
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimiter keeps a tokenBucket for each key requests are limited by.
+type rateLimiter struct {
+	limit      float64
+	burst      float64
+	byClientIP bool
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	sweepAt int
+}
+
+// rateLimitIdle is how long a tokenBucket can go unused before sweep removes
+// it, even if it hasn't refilled.
+const rateLimitIdle = time.Hour
+
+// tokenBucket holds the tokens available to a key as of `last`.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// key returns the key `r` is limited by: its pattern, followed by its client
+// IP address if the limiter is keyed by client IP.
+func (l *rateLimiter) key(r *http.Request) string {
+	if !l.byClientIP {
+		return Pattern(r)
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return Pattern(r) + " " + ip
+}
+
+// allow takes a token from the bucket for `key` at `now`, returning true if
+// there was one. If there wasn't, it returns how long until there will be,
+// unless the limit is 0 and there never will be.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		l.sweep(now)
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.tokens(b, now)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.limit <= 0 {
+		return false, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.limit * float64(time.Second))
+}
+
+// tokens returns the tokens `b` has at `now`, refilled at the limiter's rate
+// since it was last used, up to its burst.
+func (l *rateLimiter) tokens(b *tokenBucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 || l.limit <= 0 {
+		return b.tokens
+	}
+	return math.Min(l.burst, b.tokens+elapsed*l.limit)
+}
+
+// sweep removes the buckets that have refilled completely by `now`, as they'd
+// be recreated exactly as they are, so keys that are no longer used, like
+// the IP addresses of clients that went away, don't pile up. Buckets that
+// haven't been used for rateLimitIdle are removed too, as buckets never
+// refill when the limit is 0, and can take as long as they like to when
+// it's tiny. It only sweeps once the number of buckets has doubled since the
+// last sweep, so the cost is spread out over the requests that added them.
+func (l *rateLimiter) sweep(now time.Time) {
+	if len(l.buckets) < l.sweepAt {
+		return
+	}
+	for key, b := range l.buckets {
+		if l.tokens(b, now) >= l.burst || now.Sub(b.last) >= rateLimitIdle {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweepAt = 2 * len(l.buckets)
+	if l.sweepAt < 1024 {
+		l.sweepAt = 1024
+	}
+}
@@ -0,0 +1,91 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	type testCase struct {
+		url, remoteAddr string
+		code            int
+	}
+	cases := []testCase{
+		{"/posts/foo", "192.0.2.1:1234", http.StatusOK},
+		{"/posts/bar", "192.0.2.1:1234", http.StatusOK},
+		{"/posts/baz", "192.0.2.1:1234", http.StatusTooManyRequests},
+		{"/posts/foo", "192.0.2.2:1234", http.StatusTooManyRequests},
+		{"/users/foo", "192.0.2.1:1234", http.StatusOK},
+		{"/users/foo", "192.0.2.1:1234", http.StatusTooManyRequests},
+		{"/users/foo", "192.0.2.2:1234", http.StatusOK},
+	}
+	var router Router
+	router.Endpoint("/posts/{slug}").Methods("GET").Middleware(RateLimit(0.001, 2)).Handler(testHandler("post"))
+	router.Endpoint("/users/{name}").Methods("GET").Middleware(RateLimit(0.001, 1, RateLimitByClientIP())).Handler(testHandler("user"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		r.RemoteAddr = c.remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s from %s to return %d, got %d", c.url, c.remoteAddr, c.code, w.Code)
+		}
+		if c.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected %s from %s to have a Retry-After header", c.url, c.remoteAddr)
+		}
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := &rateLimiter{limit: 2, burst: 1, buckets: map[string]*tokenBucket{}}
+	now := time.Now()
+	if ok, _ := l.allow("key", now); !ok {
+		t.Fatalf("Expected the first request to be allowed")
+	}
+	ok, wait := l.allow("key", now)
+	if ok {
+		t.Fatalf("Expected the second request to be limited")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("Expected to wait 500ms, got %s", wait)
+	}
+	if ok, _ := l.allow("key", now.Add(wait)); !ok {
+		t.Errorf("Expected a request after waiting to be allowed")
+	}
+	if ok, _ := l.allow("key", now.Add(time.Hour)); !ok {
+		t.Errorf("Expected a request an hour later to be allowed")
+	}
+	if ok, _ := l.allow("key", now.Add(time.Hour)); ok {
+		t.Errorf("Expected the bucket not to refill past its burst")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	type testCase struct {
+		limit float64
+		idle  time.Duration
+		kept  bool
+	}
+	cases := []testCase{
+		{0, time.Minute, true},
+		{0, rateLimitIdle, false},
+		{0.0001, time.Minute, true},
+		{0.0001, rateLimitIdle, false},
+		{1, time.Minute, false},
+	}
+	for _, c := range cases {
+		l := &rateLimiter{limit: c.limit, burst: 1, buckets: map[string]*tokenBucket{}}
+		now := time.Now()
+		l.allow("idle", now)
+		l.sweepAt = 0
+		l.allow("new", now.Add(c.idle))
+		if _, ok := l.buckets["idle"]; ok != c.kept {
+			t.Errorf("Expected a bucket idle for %s with a limit of %v to be kept: %v, got %v", c.idle, c.limit, c.kept, ok)
+		}
+	}
+}
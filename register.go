@@ -0,0 +1,73 @@
+package trout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Route describes an Endpoint or Prefix to define on a Router using
+// Router.Register.
+type Route struct {
+	// Pattern is the URL template of the Endpoint or Prefix, following the
+	// same rules as Router.Endpoint.
+	Pattern string
+	// IsPrefix defines a Prefix instead of an Endpoint when true.
+	IsPrefix bool
+	// Methods holds the methods Handler should be used for. If it's
+	// empty, Handler is set as the default handler, like
+	// Endpoint.Handler.
+	Methods []string
+	// Handler is the http.Handler to use for requests that match the
+	// route.
+	Handler http.Handler
+	// Middleware holds any middleware to wrap Handler with, like
+	// Endpoint.Middleware.
+	Middleware []func(http.Handler) http.Handler
+}
+
+// Register defines an Endpoint or Prefix on `router` for each of `routes`, in
+// order, as though Router.Endpoint or Router.Prefix had been called for each
+// of them, followed by Methods, Handler, and Middleware.
+//
+// The Pattern of every route is parsed before any of them are defined. If any
+// of them aren't valid URL templates, none of the routes are defined, and an
+// error is returned for each invalid Pattern, joined using errors.Join.
+func (router *Router) Register(routes []Route) error {
+	parsed := make([][]key, len(routes))
+	var errs []error
+	for i, route := range routes {
+		keys, err := keysFromString(route.Pattern, router.CaseSensitive)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("trout: invalid pattern %q: %w", route.Pattern, err))
+			continue
+		}
+		if route.IsPrefix {
+			keys[len(keys)-1].prefix = true
+		}
+		parsed[i] = keys
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	for i, route := range routes {
+		var n *node
+		if route.IsPrefix {
+			n = router.add(parsed[i], false)
+		} else {
+			n = router.add(parsed[i], len(route.Pattern) > 1 && strings.HasSuffix(route.Pattern, "/"))
+		}
+		methods := normalizeMethods(route.Methods)
+		if len(methods) < 1 {
+			methods = []string{catchAllMethod}
+		}
+		for _, method := range methods {
+			n.setMethod(method, route.Handler)
+			if len(route.Middleware) > 0 {
+				n.middleware[method] = route.Middleware
+			}
+		}
+	}
+	return nil
+}
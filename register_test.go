@@ -0,0 +1,59 @@
+package trout
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Handle405 = testHandler("405")
+	err := router.Register([]Route{
+		{Pattern: "/posts", Methods: []string{"get"}, Handler: testHandler("list-posts")},
+		{Pattern: "/posts", Methods: []string{"POST"}, Handler: testHandler("create-post")},
+		{Pattern: "/posts/{id}", Handler: testHandler("post")},
+		{Pattern: "/static", IsPrefix: true, Handler: testHandler("static")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error registering routes: %+v", err)
+	}
+	cases := []struct {
+		method, url, handler string
+	}{
+		{"GET", "/posts", "list-posts"},
+		{"POST", "/posts", "create-post"},
+		{"DELETE", "/posts", "405"},
+		{"DELETE", "/posts/1", "post"},
+		{"GET", "/static/app.js", "static"},
+	}
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s %s to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	var router Router
+	err := router.Register([]Route{
+		{Pattern: "/posts", Handler: testHandler("posts")},
+		{Pattern: "/{id", Handler: testHandler("broken")},
+		{Pattern: "/{a}{b}", Handler: testHandler("broken")},
+	})
+	if err == nil {
+		t.Fatalf("Expected an error registering invalid routes, got nil")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `"/{id"`) || !strings.Contains(msg, `"/{a}{b}"`) {
+		t.Errorf("Expected the error to mention every invalid pattern, got %q", msg)
+	}
+	if router.Len() != 0 {
+		t.Errorf("Expected no routes to be defined, got %v", router.Routes())
+	}
+}
@@ -0,0 +1,68 @@
+package trout
+
+// RemoveEndpoint removes the Endpoint for the URL template `e` from `router`,
+// along with its handlers, middleware, name, and any Endpoints created from it
+// using Endpoint.Query. `e` is parsed the same way it is by Router.Endpoint,
+// so it must be written the way the Endpoint was defined, parameter names and
+// constraints included. Removing an Endpoint that doesn't exist does nothing.
+// An error is only returned if `e` isn't a valid URL template.
+//
+// It is safe to call RemoveEndpoint while `router` is serving requests;
+// requests already being routed will either see the Endpoint or not see it,
+// never part of it.
+func (router *Router) RemoveEndpoint(e string) error {
+	keys, err := keysFromString(e, router.CaseSensitive)
+	if err != nil {
+		return err
+	}
+	router.remove(nil, keys)
+	return nil
+}
+
+// RemovePrefix removes the Prefix for the URL template `p` from `router`,
+// exactly like RemoveEndpoint does for Endpoints. The Endpoints and Prefixes
+// defined under `p` are not removed.
+func (router *Router) RemovePrefix(p string) error {
+	keys, err := keysFromString(p, router.CaseSensitive)
+	if err != nil {
+		return err
+	}
+	keys[len(keys)-1].prefix = true
+	router.remove(nil, keys)
+	return nil
+}
+
+// remove removes the terminator for `keys` from the Router's trie, under the
+// root node for the host template `labels`, or the main root node if `labels`
+// is nil.
+func (router *Router) remove(labels, keys []key) {
+	if router.trie == nil {
+		return
+	}
+	router.trie.remove(labels, keys)
+}
+
+// RemoveEndpoint removes the Endpoint for the URL template `e` from the
+// Router `h` belongs to, only looking at the Endpoints defined for the host
+// template of `h`, following the same rules as Router.RemoveEndpoint.
+func (h *Host) RemoveEndpoint(e string) error {
+	keys, err := keysFromString(e, h.router.CaseSensitive)
+	if err != nil {
+		return err
+	}
+	h.router.remove(h.labels, keys)
+	return nil
+}
+
+// RemovePrefix removes the Prefix for the URL template `p` from the Router `h`
+// belongs to, only looking at the Prefixes defined for the host template of
+// `h`, following the same rules as Router.RemovePrefix.
+func (h *Host) RemovePrefix(p string) error {
+	keys, err := keysFromString(p, h.router.CaseSensitive)
+	if err != nil {
+		return err
+	}
+	keys[len(keys)-1].prefix = true
+	h.router.remove(h.labels, keys)
+	return nil
+}
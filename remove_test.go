@@ -0,0 +1,85 @@
+package trout
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/posts").Handler(testHandler("posts"))
+	router.Endpoint("/posts/{id}/comments").Name("comments").Handler(testHandler("comments"))
+	router.Endpoint("/posts/{id}/comments").Query("sort", "").Name("sorted").Handler(testHandler("sorted"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Host("api.example.com").Endpoint("/posts").Handler(testHandler("api-posts"))
+
+	if err := router.RemoveEndpoint("/posts/{id}/comments"); err != nil {
+		t.Fatalf("Unexpected error removing endpoint: %+v", err)
+	}
+	if err := router.RemovePrefix("/static"); err != nil {
+		t.Fatalf("Unexpected error removing prefix: %+v", err)
+	}
+	if err := router.RemoveEndpoint("/missing/{id}"); err != nil {
+		t.Errorf("Expected removing a missing endpoint to do nothing, got %+v", err)
+	}
+	if err := router.RemoveEndpoint("/{id"); err == nil {
+		t.Errorf("Expected an error removing an invalid template, got nil")
+	}
+	if err := router.Host("api.example.com").RemoveEndpoint("/posts"); err != nil {
+		t.Fatalf("Unexpected error removing host endpoint: %+v", err)
+	}
+
+	cases := map[string]string{
+		"/posts":                       "posts",
+		"/posts/1/comments":            "404",
+		"/posts/1/comments?sort":       "404",
+		"/static/app.js":               "404",
+		"http://api.example.com/posts": "posts",
+	}
+	for url, handler := range cases {
+		r, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", url, handler, res)
+		}
+	}
+	for _, name := range []string{"comments", "sorted"} {
+		if _, err := router.URLFor(name); err == nil {
+			t.Errorf("Expected %q to be removed", name)
+		}
+	}
+	if len(router.trie.root.children) != 1 {
+		t.Errorf("Expected empty nodes to be pruned, got %v", router.Routes())
+	}
+}
+
+func TestRemoveConcurrent(t *testing.T) {
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/posts/{id}").Handler(testHandler("post"))
+	router.Endpoint("/posts").Handler(testHandler("posts"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r, _ := http.NewRequest("GET", "/posts/1", nil)
+				h, _ := router.getHandler(r)
+				if res := string(h.(testHandler)); res != "post" && res != "404" {
+					t.Errorf("Unexpected handler %s", res)
+				}
+			}
+		}()
+	}
+	if err := router.RemoveEndpoint("/posts/{id}"); err != nil {
+		t.Errorf("Unexpected error removing endpoint: %+v", err)
+	}
+	wg.Wait()
+}
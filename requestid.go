@@ -0,0 +1,77 @@
+package trout
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header RequestID uses when it isn't given
+// one.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID RequestID will accept from a
+// request header.
+const maxRequestIDLength = 128
+
+// requestIDContextKey is the key the request ID is stored under in the
+// request context.
+var requestIDContextKey = &contextKey{"requestID"}
+
+// RequestID returns middleware that gives each request an ID, read from the
+// request's `header` if it has a valid one, or generated if it doesn't. The
+// ID is stored in the request's context, where RequestIDFromRequest can read
+// it, set in the request's `header`, and echoed back in the response's
+// `header`. If `header` is empty, DefaultRequestIDHeader is used. IDs read
+// from requests must be made up of up to 128 printable ASCII characters.
+// Generated IDs are 32 random hex characters.
+//
+// RequestID should be used as pre-middleware, set using
+// Router.SetPreMiddleware, so that the ID is available to everything that
+// runs after it, including the Router's OnMatch callback and middleware like
+// AccessLog, which logs it.
+func RequestID(header string) func(http.Handler) http.Handler {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !validRequestID(id) {
+				id = newRequestID()
+				r.Header.Set(header, id)
+			}
+			w.Header().Set(header, id)
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+		})
+	}
+}
+
+// RequestIDFromRequest returns the ID the RequestID middleware gave `r`, or
+// an empty string if `r` didn't pass through it.
+func RequestIDFromRequest(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// validRequestID returns true if `id` is a request ID RequestID will accept
+// from a request.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:]) //nolint:errcheck
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,60 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	type testCase struct {
+		header, inbound string
+		generated       bool
+	}
+	cases := []testCase{
+		{"", "abc-123", false},
+		{"", "", true},
+		{"", "has spaces", true},
+		{"", strings.Repeat("a", 129), true},
+		{"X-Correlation-ID", "xyz", false},
+	}
+	for _, c := range cases {
+		header := c.header
+		if header == "" {
+			header = DefaultRequestIDHeader
+		}
+		var matched, handled string
+		router := NewRouter(WithOnMatch(func(r *http.Request, pattern string, ok bool, status int) {
+			matched = RequestIDFromRequest(r)
+		}))
+		router.SetPreMiddleware(RequestID(c.header))
+		router.Endpoint("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled = RequestIDFromRequest(r)
+			if got := r.Header.Get(header); got != handled {
+				t.Errorf("Expected the request's %s header to be %q, got %q", header, handled, got)
+			}
+		})
+		r, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %+v", err)
+		}
+		if c.inbound != "" {
+			r.Header.Set(header, c.inbound)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if c.generated && (len(handled) != 32 || handled == c.inbound) {
+			t.Errorf("Expected a generated ID for %q, got %q", c.inbound, handled)
+		}
+		if !c.generated && handled != c.inbound {
+			t.Errorf("Expected the ID %q to be used, got %q", c.inbound, handled)
+		}
+		if matched != handled {
+			t.Errorf("Expected OnMatch to see the ID %q, got %q", handled, matched)
+		}
+		if echoed := w.Header().Get(header); echoed != handled {
+			t.Errorf("Expected the response's %s header to be %q, got %q", header, handled, echoed)
+		}
+	}
+}
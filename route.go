@@ -1,10 +1,15 @@
 package trout
 
 import (
-	"math"
+	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,18 +17,88 @@ const (
 	catchAllMethod = "*"
 )
 
+// standardMethods are the methods defined by the HTTP specification, which
+// are reported as supported by a catch-all handler that excludes methods.
+var standardMethods = []string{
+	http.MethodConnect,
+	http.MethodDelete,
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodPatch,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodTrace,
+}
+
 var (
+	// the bodies and Content-Type of the default 404 and 405 responses
+	// are only built once, as scanners and misbehaving clients can make
+	// them some of the most common responses a server sends
+	notFoundBody         = []byte("404 Page Not Found")
+	methodNotAllowedBody = []byte("405 Method Not Allowed")
+	textPlainContentType = []string{"text/plain; charset=utf-8"}
+
 	default404Handler = http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = textPlainContentType
 		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404 Page Not Found")) //nolint:errcheck
+		w.Write(notFoundBody) //nolint:errcheck
 	}))
 	default405Handler = http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Allow", strings.Join(r.Header[http.CanonicalHeaderKey("Trout-Methods")], ", "))
+		w.Header().Set("Allow", allowFromRequest(r))
+		w.Header()["Content-Type"] = textPlainContentType
 		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte("405 Method Not Allowed")) //nolint:errcheck
+		w.Write(methodNotAllowedBody) //nolint:errcheck
 	}))
 )
 
+// DefaultMaxPathSegments is the most segments a request path can have when
+// a Router's MaxPathSegments property isn't set.
+const DefaultMaxPathSegments = 100
+
+// uriTooLongHandler responds to requests whose paths have more segments than
+// a Router allows.
+var uriTooLongHandler = http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header()["Content-Type"] = textPlainContentType
+	w.WriteHeader(http.StatusRequestURITooLong)
+	w.Write([]byte("414 URI Too Long")) //nolint:errcheck
+}))
+
+// maxPathSegments returns the most segments the Router allows request paths
+// to have, or a negative number if there's no limit.
+func (router Router) maxPathSegments() int {
+	if router.MaxPathSegments == 0 {
+		return DefaultMaxPathSegments
+	}
+	return router.MaxPathSegments
+}
+
+// tooManySegments returns true if `u`, a path returned by matchPath, would
+// be split into more pieces than the Router allows. Escaped slashes don't
+// split pieces, and neither do slashes at the start or end of `u`.
+func (router Router) tooManySegments(u string) bool {
+	limit := router.maxPathSegments()
+	return limit >= 0 && strings.Count(strings.Trim(u, "/"), "/")+1 > limit
+}
+
+// allowFromRequest returns the value of an Allow header for the methods the
+// route that matched `r` has handlers for, like joinMethods. The methods of a
+// route are already sorted and de-duplicated, so they're only joined, and a
+// single method is used as it is.
+func allowFromRequest(r *http.Request) string {
+	rt := routeFromRequest(r)
+	if rt == nil {
+		return joinMethods(methodsFromRequest(r))
+	}
+	switch len(rt.methods) {
+	case 0:
+		return ""
+	case 1:
+		return rt.methods[0]
+	}
+	return strings.Join(rt.methods, ", ")
+}
+
 // RequestVars returns easy-to-access mappings of parameters to values for URL
 // templates. Any {parameter} in your URL template will be available in the
 // returned Header as a slice of strings, one for each instance of the
@@ -37,8 +112,19 @@ var (
 // using .Get(), the parameter name will be transformed automatically. When
 // utilising the Header as a map, the parameter name needs to have
 // http.CanonicalHeaderKey applied manually.
+//
+// Parameters are read from the request context the Router stores them in.
+// For requests that weren't routed by a Router, they're read from the
+// Trout-Param-* headers set when Router.LegacyHeaderVars is true.
 func RequestVars(r *http.Request) http.Header {
 	res := http.Header{}
+	if rt := routeFromRequest(r); rt != nil {
+		for _, p := range rt.orderedParams {
+			key := http.CanonicalHeaderKey(p.Name)
+			res[key] = append(res[key], p.Value)
+		}
+		return res
+	}
 	for h, v := range r.Header {
 		stripped := strings.TrimPrefix(h, http.CanonicalHeaderKey("Trout-Param-"))
 		if stripped != h {
@@ -48,6 +134,42 @@ func RequestVars(r *http.Request) http.Header {
 	return res
 }
 
+// Param is a single parameter of the Endpoint or Prefix that matched a
+// request.
+type Param struct {
+	// Name is the name of the parameter, as it was written in the
+	// template.
+	Name string
+	// Value is the value the parameter was given in the request path.
+	Value string
+}
+
+// OrderedVars returns the parameters of the Endpoint or Prefix that matched
+// `r`, in the order they appear in the path. Unlike RequestVars, parameter
+// names are returned as they were written in the template. When a parameter
+// name is used more than once, each value is returned in the position it
+// appeared in the path.
+//
+// OrderedVars relies on the routing information the Router stores in the
+// request context, and returns nil for requests that weren't routed by a
+// Router.
+func OrderedVars(r *http.Request) []Param {
+	if rt := routeFromRequest(r); rt != nil {
+		return rt.orderedParams
+	}
+	return nil
+}
+
+// paramsMap returns `params` as a mapping of parameter names to the values
+// assigned to them, in the order they appear in `params`.
+func paramsMap(params []Param) map[string][]string {
+	res := make(map[string][]string, len(params))
+	for _, p := range params {
+		res[p.Name] = append(res[p.Name], p.Value)
+	}
+	return res
+}
+
 // Router defines a set of Endpoints that map requests to the http.Handlers.
 // The http.Handler assigned to Handle404, if set, will be called when no
 // Endpoint matches the current request. The http.Handler assigned to
@@ -64,15 +186,182 @@ func RequestVars(r *http.Request) http.Header {
 // and then start serving requests. Using them outside of this use case is
 // unsupported.
 type Router struct {
-	Handle404  http.Handler
-	Handle405  http.Handler
-	prefix     string
-	trie       *trie
-	middleware []func(http.Handler) http.Handler
+	Handle404 http.Handler
+	Handle405 http.Handler
+
+	// MaxPathSegments is the most `/`-separated segments the path of a
+	// request can have, counted the way they're matched: after any prefix
+	// set using SetPrefix, ignoring a trailing slash, with an escaped
+	// slash kept inside its segment. Requests with longer paths get a 414
+	// response without being routed, as routing them is work an attacker
+	// could use to exhaust the server. When MaxPathSegments is 0,
+	// DefaultMaxPathSegments is used. When it's negative, paths can be
+	// any length.
+	MaxPathSegments int
+
+	// ErrorHandler, when set, is used to respond to requests whose
+	// Handler, set using Handle, returned an error. It's the one
+	// place errors are mapped to responses, so handlers can return
+	// them instead of writing their own. When ErrorHandler is nil, a
+	// 500 is sent instead.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// RedirectTrailingSlash, when set to true, will redirect requests
+	// that match an Endpoint but don't match its trailing slash to the
+	// form the Endpoint was first defined with. So if the Endpoint was
+	// defined as `/posts/{id}`, a request for `/posts/foo/` will be
+	// redirected to `/posts/foo`, and vice versa. GET and HEAD requests
+	// are redirected with a 301, all other methods are redirected with
+	// a 308 so the method and body are preserved. The query string is
+	// always preserved. Prefixes and catch-all parameters are never
+	// redirected.
+	RedirectTrailingSlash bool
+
+	// HandleOPTIONS, when set to true, will automatically respond to
+	// OPTIONS requests for any Endpoint or Prefix that doesn't have an
+	// OPTIONS handler set using the Methods method. The response will
+	// be a 204 with the Allow header set to the methods the Endpoint or
+	// Prefix has handlers for. A default handler set with the Handler
+	// method does not count as an OPTIONS handler, but Endpoints and
+	// Prefixes with only a default handler will leave OPTIONS requests
+	// to it, unless the CatchAllMethods property is set. Server-wide
+	// `OPTIONS *` requests will also get a 204, with the Allow header set
+	// to every method any Endpoint or Prefix has a handler for.
+	HandleOPTIONS bool
+
+	// HandleHEAD, when set to true, will serve HEAD requests that would
+	// otherwise get a 405 using the GET handler and any middleware set
+	// for GET, if a GET handler can be found for the request. The
+	// response body the GET handler writes will be discarded, but the
+	// headers and status code will be sent as-is, with a Content-Length
+	// computed from the discarded body if the handler didn't set one.
+	HandleHEAD bool
+
+	// DisableMethodNotAllowed, when set to true, will respond to
+	// requests that match an Endpoint or Prefix that has no handler for
+	// their method with the 404 response, instead of a 405 response with
+	// an Allow header, so the response doesn't reveal that anything
+	// exists at the request's path. HandleHEAD and HandleOPTIONS still
+	// apply if they're set.
+	DisableMethodNotAllowed bool
+
+	// LegacyHeaderVars, when set to true, will set a Trout-Param-*
+	// header on each request for each parameter in the matched
+	// Endpoint or Prefix, as older versions of trout did. Parameters
+	// are always available through RequestVars, which doesn't need
+	// the headers; the headers will be passed on if the request is
+	// proxied, and should only be used by code that reads them
+	// directly.
+	LegacyHeaderVars bool
+
+	// DisableHeaders, when set to true, will stop the Router from
+	// setting the Trout-Methods, Trout-Pattern, Trout-Param-*, and
+	// Trout-Timer headers on requests, even if LegacyHeaderVars or
+	// Timing are set. The same information is always available through
+	// RequestVars, Pattern, Elapsed, and the other functions that read
+	// it from the request context. Trout- headers sent by the client
+	// are still removed.
+	DisableHeaders bool
+
+	// Timing, when set to true, will time how long it takes to route
+	// each request, making it available through the Trout-Timer header
+	// and the Elapsed function. Timing is off by default, to avoid its
+	// cost for Routers that don't use it.
+	Timing bool
+
+	// CleanPath, when set to true, will collapse repeated `/`
+	// characters and resolve `.` and `..` path elements in the request
+	// path before matching it, so `/posts//foo` and `/posts/./foo` will
+	// both match `/posts/{slug}`. A trailing `/` is preserved. When
+	// CleanPath is false, the request path is matched as-is, and empty
+	// or `.` path elements will be matched like any other path element.
+	CleanPath bool
+
+	// CaseSensitive, when set to true, will match the static path
+	// elements of Endpoints and Prefixes, the literal text around their
+	// parameters, and their lists of allowed values exactly, instead of
+	// case-insensitively. Parameter values always keep the case they
+	// had in the request, and regular expression constraints are always
+	// case-sensitive, whether or not CaseSensitive is set. Hosts are
+	// always matched case-insensitively.
+	//
+	// CaseSensitive is read when Endpoints and Prefixes are defined, as
+	// well as when requests are routed, so it must be set before any
+	// Endpoints or Prefixes are defined.
+	CaseSensitive bool
+
+	// SegmentMatcher, when set, is used to decide whether a static path
+	// element of an Endpoint or Prefix matches a piece of a request's
+	// path, instead of comparing them. It's called with the path element
+	// as it was defined, lowercased unless CaseSensitive is set, and
+	// the unescaped piece of the request path, in its original case,
+	// and should return true if they match. This allows, for example,
+	// matching Unicode-normalized forms of path elements. Parameters,
+	// and the literal text around them, are matched as usual.
+	//
+	// Setting SegmentMatcher means every static path element under a
+	// node has to be tried, instead of looking up the one that matches,
+	// so routing is slower when it's set.
+	SegmentMatcher func(key, piece string) bool
+
+	// OnMatch, when set, is called once for every request the Router
+	// routes, after the routing decision has been made and before the
+	// request is served. It's called with the request, the pattern of
+	// the Endpoint or Prefix that matched it, whether one did, and the
+	// status code the Router decided on: a 404 when nothing matched,
+	// with an empty pattern, a 405 when the Endpoint or Prefix has no
+	// handler for the request's method, a 301 or 308 for trailing
+	// slash redirects, a 204 for OPTIONS requests the Router answers
+	// itself, and a 200 when a handler was found, whatever status the
+	// handler ends up responding with.
+	OnMatch func(r *http.Request, pattern string, matched bool, status int)
+
+	// CatchAllMethods, when set, holds the methods to report for the
+	// default handlers of Endpoints and Prefixes, set using Handler or
+	// Any, which serve every method and so don't say which methods they
+	// support. They're reported anywhere the methods of an Endpoint or
+	// Prefix are: the Trout-Methods header, Allow headers, RouteMatch,
+	// and AllowedMethods. They're also used by HandleOPTIONS, which
+	// normally leaves OPTIONS requests to Endpoints and Prefixes with
+	// only a default handler to the default handler. CatchAllMethods
+	// doesn't change which requests default handlers serve, and should
+	// use uppercase method names.
+	//
+	// When CatchAllMethods is empty, default handlers aren't reported,
+	// except for default handlers set using MethodsExcept, which
+	// report the standard HTTP methods they serve.
+	CatchAllMethods []string
+
+	prefix        string
+	trie          *trie
+	middleware    []func(http.Handler) http.Handler
+	preMiddleware []func(http.Handler) http.Handler
+	next          http.Handler
+}
+
+// NotFoundNext sets `next` as the http.Handler that requests no Endpoint or
+// Prefix matches are passed on to, instead of the Router's 404 handler. This
+// allows multiple Routers to be chained together, with each one trying the
+// next if it can't serve a request; the last one in the chain should respond
+// with a 404 as usual. Requests that match an Endpoint or Prefix that doesn't
+// have a handler for the request's method still get a 405, and aren't passed
+// on. Passing nil restores the 404 handler.
+//
+// NotFoundNext is not concurrency-safe, and should not be used while the
+// Router is actively routing traffic.
+func (router *Router) NotFoundNext(next http.Handler) {
+	router.next = next
 }
 
 // get404 returns the http.Handler `router` should use when serving a 404 page
-func (router Router) get404() http.Handler {
+// for a request whose path led to `n`, which may be nil.
+func (router Router) get404(n *node) http.Handler {
+	if h := nearestHandler(n, func(t *node) http.Handler { return t.notFound }); h != nil {
+		return h
+	}
+	if router.next != nil {
+		return router.next
+	}
 	h := default404Handler
 	if router.Handle404 != nil {
 		h = router.Handle404
@@ -81,7 +370,11 @@ func (router Router) get404() http.Handler {
 }
 
 // get405 returns the http.Handler `router` should use when serving a 405 page
-func (router Router) get405() http.Handler {
+// for a request that matched `n`.
+func (router Router) get405(n *node) http.Handler {
+	if h := nearestHandler(n, func(t *node) http.Handler { return t.methodNotAllowed }); h != nil {
+		return h
+	}
 	h := default405Handler
 	if router.Handle405 != nil {
 		h = router.Handle405
@@ -100,51 +393,151 @@ type route struct {
 	handler http.Handler
 	// the pattern that was matched
 	pattern string
-	// the parsed parameters from the pattern
-	params map[string][]string
-	// the methods this endpoint can serve
+	// the parsed parameters from the pattern, in the order they appear
+	// in the path. They're only gathered into a map, using paramsMap,
+	// when one is asked for, so routing a request doesn't allocate one
+	// that could be shared with handlers that outlive the request.
+	orderedParams []Param
+	// the methods this endpoint has handlers for, sorted, not including
+	// the catch-all method. If the catch-all handler excludes methods,
+	// the standard methods it serves are included.
 	methods []string
 	// middleware to use when serving the handler on this route
 	middleware []func(http.Handler) http.Handler
+	// the terminating node that was matched
+	node *node
+	// whether the matched node was a prefix
+	prefix bool
+	// the unescaped pieces of the path after the matched prefix, joined
+	// by /, if the matched node was a prefix
+	remainder string
+	// whether the matched node was a catch-all parameter
+	catchAll bool
+	// whether the handler is a GET handler serving a HEAD request
+	head bool
+	// the request path that was matched, after any Router prefix was
+	// removed
+	path string
+	// how long it took to route the request
+	elapsed time.Duration
+	// whether the matched node was defined with a trailing slash
+	trailingSlash bool
+	// the ErrorHandler of the Router that routed the request
+	errorHandler func(http.ResponseWriter, *http.Request, error)
 }
 
 // route uses the pieces of the request URL and the method of the request to
 // find a route that should be used to serve the request.
 //
-// routes are chosen based on a weighting; see `scoreNode` for more details on
+// routes are chosen based on a weighting; see `compareScores` for more details on
 // the algorithm. routes that can support the supplied method are always chosen
 // over routes that cannot; if a route that cannot support the supplied method
 // is returned, it is safe to assume no route can.
-func (router Router) route(pieces []string, method string) *route {
-	result := &route{}
-	nodes := router.trie.findNodes(pieces)
-	if nodes == nil || len(nodes) < 1 {
-		return nil
+func (router Router) route(host string, pieces, folded []string, method string, query *requestQuery) *route {
+	result := router.routeMethod(host, pieces, folded, method, query)
+	if result == nil || result.handler != nil || !router.HandleHEAD || method != http.MethodHead {
+		return result
 	}
-	node := pickNode(nodes, pieces, method)
+	get := router.routeMethod(host, pieces, folded, http.MethodGet, query)
+	if get == nil || get.handler == nil {
+		return result
+	}
+	get.head = true
+	return get
+}
+
+// routeMethod finds the route that should be used to serve the request, as
+// described by `route`, without any special handling for HEAD requests.
+// `host` is the host of the request, as returned by `requestHost`,
+// `folded` holds the pieces static nodes are matched against, as returned by
+// `Router.fold`, and
+// `query` holds the query string of the request.
+func (router Router) routeMethod(host string, pieces, folded []string, method string, query *requestQuery) *route {
+	result := &route{}
+	node, hostParams := router.trie.pickNode(host, pieces, folded, router.SegmentMatcher, method, query)
 	if node == nil {
 		return nil
 	}
-	result.params = router.trie.vars(node, pieces)
-	result.pattern = strings.TrimSuffix(router.prefix, "/") + router.trie.pathString(node)
-	for method := range node.methods {
-		result.methods = append(result.methods, method)
+	result.node = node
+	result.orderedParams = router.trie.orderedVars(node, pieces)
+	if len(hostParams) > 0 {
+		params := make([]Param, 0, len(hostParams)+len(result.orderedParams))
+		params = append(params, hostParams...)
+		result.orderedParams = append(params, result.orderedParams...)
 	}
+	result.prefix = node.parent != nil && node.parent.value.prefix
+	if result.prefix && node.parent.depth < len(pieces) {
+		result.remainder = strings.Join(pieces[node.parent.depth:], "/")
+	}
+	result.catchAll = node.parent != nil && node.parent.value.catchAll
+	result.trailingSlash = node.trailingSlash
+	result.pattern = router.trie.pattern(router.prefix, node)
+	result.methods = terminatorMethods(node, router.CatchAllMethods)
 	var ok bool
 	result.handler, ok = node.methods[method]
-	result.middleware = node.middleware[method]
-	if !ok {
+	// the default middleware applies to every request, with the
+	// middleware for the request's method, if it has its own handler,
+	// running inside it
+	defaultMiddleware := node.middleware[catchAllMethod]
+	var methodMiddleware []func(http.Handler) http.Handler
+	if ok {
+		methodMiddleware = node.middleware[method]
+	} else if !node.excludedMethods[method] {
 		result.handler = node.methods[catchAllMethod]
-		result.middleware = node.middleware[catchAllMethod]
+	}
+	if len(node.groupMiddleware) < 1 && len(methodMiddleware) < 1 {
+		result.middleware = defaultMiddleware
+	} else if len(node.groupMiddleware) < 1 && len(defaultMiddleware) < 1 {
+		result.middleware = methodMiddleware
+	} else {
+		result.middleware = make([]func(http.Handler) http.Handler, 0, len(node.groupMiddleware)+len(defaultMiddleware)+len(methodMiddleware))
+		result.middleware = append(result.middleware, node.groupMiddleware...)
+		result.middleware = append(result.middleware, defaultMiddleware...)
+		result.middleware = append(result.middleware, methodMiddleware...)
 	}
 	return result
 }
 
-// pickNode selects a node that has the highest score, according to
-// `scoreNode`, to serve a request.
-func pickNode(nodes []*node, pieces []string, method string) *node {
-	var maxScore float64
-	var bestNode *node
+// terminatorMethods returns the methods the terminator `n` has handlers for,
+// sorted, not including the catch-all method. If `n` has a catch-all handler,
+// `catchAll` is included as the methods it serves, as set in the Router's
+// CatchAllMethods property. If the catch-all handler excludes methods, the
+// methods in `catchAll`, or the standard methods if it's empty, are included
+// instead, without the excluded methods.
+func terminatorMethods(n *node, catchAll []string) []string {
+	_, ok := n.methods[catchAllMethod]
+	if !ok || (len(n.excludedMethods) < 1 && len(catchAll) < 1) {
+		return n.methodList
+	}
+	methods := make([]string, 0, len(n.methodList)+len(catchAll))
+	methods = append(methods, n.methodList...)
+	if len(n.excludedMethods) > 0 {
+		// we can't list every method the catch-all handler serves,
+		// but we can list the standard ones, so clients know what
+		// they can use instead of the excluded methods
+		if len(catchAll) < 1 {
+			catchAll = standardMethods
+		}
+		for _, method := range catchAll {
+			if !n.excludedMethods[method] {
+				methods = append(methods, method)
+			}
+		}
+		return sortMethods(methods)
+	}
+	return sortMethods(append(methods, catchAll...))
+}
+
+// pickNode selects a terminator to serve a request. Terminators that can
+// serve the request's method are always preferred, then terminators with the
+// highest priority, then terminators whose nodes have the highest score,
+// according to `compareScores`, and then terminators that require the most
+// query parameters. Terminators that require query parameters `query`
+// doesn't have are never picked.
+func pickNode(nodes []*node, method string, query *requestQuery) *node {
+	var maxPriority int
+	var bestSupported bool
+	var bestNode, bestTerm *node
 	for _, node := range nodes {
 		if node == nil {
 			continue
@@ -156,96 +549,367 @@ func pickNode(nodes []*node, pieces []string, method string) *node {
 			continue
 		}
 
-		score := scoreNode(node, pieces, 0)
+		for i := -1; i < len(node.terminator.queryVariants); i++ {
+			term := node.terminator
+			if i >= 0 {
+				term = term.queryVariants[i]
+				if !matchesQuery(term, query) {
+					continue
+				}
+			}
+			supported := supportsMethod(term, method)
+			priority := term.priority
 
-		// any path that can serve the specified method should score
-		// higher than paths that cannot
-		if _, ok := node.terminator.methods[method]; !ok {
-			score = score - math.Pow10(len(pieces)+1)
-		}
-		if bestNode == nil || score > maxScore {
-			maxScore = score
-			bestNode = node
+			// a terminator that can serve the method always beats
+			// one that can't, then a higher priority always beats a
+			// lower one, and only then does the score matter. These
+			// are compared in turn, rather than folded into a single
+			// number, so no path is deep enough to make them overlap.
+			var better bool
+			switch {
+			case bestNode == nil:
+				better = true
+			case supported != bestSupported:
+				better = supported
+			case priority != maxPriority:
+				better = priority > maxPriority
+			default:
+				cmp := compareScores(node, bestNode)
+				if cmp == 0 {
+					cmp = len(term.query) - len(bestTerm.query)
+				}
+				better = cmp > 0
+			}
+			if better {
+				maxPriority = priority
+				bestSupported = supported
+				bestNode = node
+				bestTerm = term
+			}
 		}
 	}
-	if bestNode == nil {
-		return nil
+	return bestTerm
+}
+
+// supportsMethod returns true if the terminator `term` has a handler for
+// `method`. Any path that can serve the specified method should be chosen
+// over paths that cannot, no matter how they score.
+func supportsMethod(term *node, method string) bool {
+	_, ok := term.methods[method]
+	return ok
+}
+
+// weightNode assigns a raw weight to how good a match a single node is,
+// without taking its ancestors into account. A higher weight is a better
+// match.
+//
+// nodes that are dynamic should weigh less than static matches
+// nodes that are dynamic and constrained should weigh more than dynamic
+// matches, but less than static matches
+// nodes that are constrained to a list of values should weigh the same as
+// constrained matches
+// nodes that are dynamic with literal text around them should weigh the
+// same as constrained matches
+// nodes that are prefixes should weigh less than static matches
+func weightNode(node *node) int {
+	return weightKey(node.value)
+}
+
+// weightKey assigns the weight `weightNode` describes to the key of a node,
+// or a label of a host template.
+func weightKey(k key) int {
+	if k.nul {
+		return 0
+	}
+	weight := 1
+	if k.re != nil || k.enum != nil || k.mixed() {
+		weight++
 	}
-	return bestNode.terminator
+	if !k.dynamic && !k.prefix {
+		weight += 2
+	}
+	return weight
 }
 
-// scoreNode assigns a raw score to how good a match a node is for a given set
-// of pieces. A higher score is a better match.
+// compareScores compares how good a match two nodes are for the same set of
+// pieces, returning a positive number if `a` is a better match than `b`, a
+// negative number if `b` is a better match than `a`, and 0 if they're equally
+// good.
 //
 // paths that have a 1:1 match between pieces and nodes should score higher
-//   - this should be taken care of by having more nodes to score
+//   - this is taken care of by deeper nodes always winning
 //
-// nodes that are dynamic should score lower than static matches
-// nodes that are prefixes should score lower than static matches
 // nodes that are prefixes should score lower than nodes that are dynamic
-//   - this should be taken care of by having more nodes to score
+//   - this is taken care of by deeper nodes always winning
 //
 // nodes earlier in the path should be worth more than nodes later in the path
-func scoreNode(node *node, pieces []string, power int) float64 {
-	var score float64
-	if node.parent != nil {
-		parPower := power + 1
-		score = scoreNode(node.parent, pieces[:len(pieces)-1], parPower)
-	}
-	if node.value.nul {
-		return score
+//   - between nodes of the same depth, the first difference in weight,
+//     starting from the root, decides
+//
+// Scores are compared element by element instead of being summed into a
+// single number, so they never lose precision, no matter how deep the path.
+func compareScores(a, b *node) int {
+	if a.depth != b.depth {
+		return a.depth - b.depth
 	}
-	myScore := 1
-	if !node.value.dynamic && !node.value.prefix {
-		myScore++
+	var result int
+	// walk up from the nodes to the root, so the last difference we see
+	// is the one closest to the root. Compacted nodes cover more than one
+	// path element, so only move up from whichever node's path elements
+	// we've run out of.
+	for a != nil && b != nil {
+		if diff := weightNode(a) - weightNode(b); diff != 0 {
+			result = diff
+		}
+		aTop, bTop := a.depth-a.span(), b.depth-b.span()
+		if aTop >= bTop {
+			a = a.parent
+		}
+		if bTop >= aTop {
+			b = b.parent
+		}
 	}
-	score += math.Pow10(power) * float64(myScore)
-	return score
+	return result
 }
 
-func (router Router) getHandler(r *http.Request) http.Handler {
-	// do our time tracking
-	start := time.Now()
-	defer func() {
-		r.Header.Set("Trout-Timer", strconv.FormatInt(time.Since(start).Nanoseconds(), 10))
-	}()
+// scoreWeights returns the weight of each node from the root of the trie
+// down to `node`, the sequence of numbers `compareScores` compares.
+func scoreWeights(node *node) []int {
+	weights := make([]int, node.depth+1)
+	for n := node; n != nil; n = n.parent {
+		for depth := n.depth - n.span() + 1; depth <= n.depth; depth++ {
+			weights[depth] = weightNode(n)
+		}
+	}
+	return weights
+}
 
+// routeRequest breaks the URL of `r` down into pieces and finds the route
+// that should be used to serve it, returning nil if there is none.
+func (router Router) routeRequest(r *http.Request) *route {
 	// if our router is nil, everything's a 404
 	if router.trie == nil {
-		return router.get404()
+		return nil
+	}
+
+	// break the request URL down into pieces, reusing the slices from an
+	// earlier request if we can. Nothing we return holds on to the
+	// pieces, so they can go back in the pool as soon as we're done.
+	scratch := piecesPool.Get().(*pathPieces)
+	defer scratch.release()
+	u, pieces := router.splitPath(r, scratch.pieces[:0])
+	scratch.pieces = pieces
+	if router.tooManySegments(u) {
+		return nil
+	}
+	folded := pieces
+	if !router.CaseSensitive {
+		folded = foldPieces(scratch.folded[:0], pieces)
+		scratch.folded = folded
+	}
+	scratch.query = requestQuery{raw: r.URL.RawQuery}
+
+	// find the best match for our pieces, request method, and query
+	result := router.route(requestHost(r), pieces, folded, r.Method, &scratch.query)
+	if result != nil {
+		result.path = u
+	}
+	return result
+}
+
+// pathPieces holds the slices a request path is broken down into, and the
+// request's query string, so they can be reused between requests.
+type pathPieces struct {
+	pieces []string
+	folded []string
+	query  requestQuery
+}
+
+// piecesPool holds *pathPieces that aren't being used to route a request.
+var piecesPool = sync.Pool{
+	New: func() interface{} {
+		return new(pathPieces)
+	},
+}
+
+// release clears `p`, so it doesn't keep the request's path alive, and puts
+// it back in piecesPool.
+func (p *pathPieces) release() {
+	for i := range p.pieces {
+		p.pieces[i] = ""
+	}
+	for i := range p.folded {
+		p.folded[i] = ""
+	}
+	p.query = requestQuery{}
+	piecesPool.Put(p)
+}
+
+// splitPath returns the path of `r` that should be matched, with any prefix
+// set using SetPrefix removed, and the pieces of that path, appended to
+// `dst`.
+//
+// The escaped path is used so an escaped / inside a piece doesn't split it,
+// then each piece is unescaped on its own.
+func (router Router) splitPath(r *http.Request, dst []string) (string, []string) {
+	u := router.matchPath(r)
+	pieces := splitPieces(dst, strings.Trim(u, "/"))
+	for i, piece := range pieces {
+		if strings.IndexByte(piece, '%') < 0 {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(piece); err == nil {
+			pieces[i] = unescaped
+		}
+	}
+	return u, pieces
+}
+
+// matchPath returns the escaped path of `r` that should be matched, as
+// returned by requestPath, with any prefix set using SetPrefix removed.
+func (router Router) matchPath(r *http.Request) string {
+	return strings.TrimPrefix(router.requestPath(r), router.prefix)
+}
+
+// requestPath returns the escaped path of `r`, cleaned if the Router's
+// CleanPath property is set.
+func (router Router) requestPath(r *http.Request) string {
+	u := r.URL.EscapedPath()
+	if router.CleanPath {
+		u = cleanPath(u)
+	}
+	return u
+}
+
+// splitPieces appends each /-separated piece of `path` to `dst`, the same
+// pieces strings.Split would return, without allocating a new slice if `dst`
+// has room for them.
+func splitPieces(dst []string, path string) []string {
+	for {
+		i := strings.IndexByte(path, '/')
+		if i < 0 {
+			return append(dst, path)
+		}
+		dst = append(dst, path[:i])
+		path = path[i+1:]
+	}
+}
+
+// fold returns the pieces static nodes should be matched against: `pieces`
+// itself if the Router is case-sensitive, or `pieces` lowercased and appended
+// to `dst` if it isn't.
+func (router Router) fold(dst, pieces []string) []string {
+	if router.CaseSensitive {
+		return pieces
 	}
+	return foldPieces(dst, pieces)
+}
+
+// foldPieces appends each of `pieces`, lowercased, to `dst`, for matching
+// against static nodes.
+func foldPieces(dst, pieces []string) []string {
+	for _, piece := range pieces {
+		dst = append(dst, strings.ToLower(piece))
+	}
+	return dst
+}
 
-	// break the request URL down into pieces
-	u := strings.TrimPrefix(r.URL.Path, router.prefix)
-	pieces := strings.Split(strings.Trim(u, "/"), "/")
+// getHandler returns the http.Handler that should serve `r`, and the request
+// it should serve, which carries the routing information in its context.
+func (router Router) getHandler(r *http.Request) (http.Handler, *http.Request) {
+	// never trust any trout headers the client sent us
+	stripTroutHeaders(r)
+
+	// OPTIONS * asks about the server as a whole, not any path, so answer
+	// it with every method we have a handler for, if we've been asked to
+	// answer OPTIONS requests
+	if router.HandleOPTIONS && r.Method == http.MethodOptions && r.URL.Path == "*" {
+		router.matched(r, "", false, http.StatusNoContent)
+		return optionsHandler(router.methods()), r
+	}
+
+	// don't even try to route paths that are longer than we allow
+	if router.tooManySegments(router.matchPath(r)) {
+		router.matched(r, "", false, http.StatusRequestURITooLong)
+		return uriTooLongHandler, r
+	}
+
+	info := &route{}
+
+	// find the best match for our request
+	var start time.Time
+	if router.Timing {
+		start = time.Now()
+	}
+	route := router.routeRequest(r)
+
+	// store what we found in the context, even if it's nothing, so the
+	// timing is available to whatever handler we use
+	if route != nil {
+		info = route
+	}
+	info.errorHandler = router.ErrorHandler
+	r = withRoute(r, info)
 
-	// find the best match for our pieces and request method
-	route := router.route(pieces, r.Method)
+	// do our time tracking, if we've been asked to
+	if router.Timing {
+		defer recordTiming(r, info, start, !router.DisableHeaders)
+	}
 
 	// if we're nil, nothing was found, it's a 404
 	if route == nil {
-		return router.get404()
+		router.matched(r, "", false, http.StatusNotFound)
+		return router.get404(router.closestNode(r)), r
+	}
+
+	// if the route expects a different trailing slash than we got,
+	// redirect to the form the route expects
+	if router.RedirectTrailingSlash && !route.prefix && !route.catchAll && route.path != "/" && route.path != "" {
+		if hasSlash := strings.HasSuffix(route.path, "/"); hasSlash != route.trailingSlash {
+			router.matched(r, route.pattern, true, redirectStatus(r.Method))
+			return trailingSlashRedirect(r, router.requestPath(r), route.trailingSlash), r
+		}
+	}
+
+	// answer OPTIONS requests ourselves if we've been asked to and there's
+	// no handler explicitly set up to answer them. If the only handler is
+	// the default one, we don't know what methods to report unless we've
+	// been told what they are, so leave it to the default handler.
+	if router.HandleOPTIONS && r.Method == http.MethodOptions && (hasExplicitMethods(route.node) || len(router.CatchAllMethods) > 0) {
+		if _, ok := route.node.methods[http.MethodOptions]; !ok {
+			router.matched(r, route.pattern, true, http.StatusNoContent)
+			return optionsHandler(route.methods), r
+		}
 	}
 
 	// if anything was found all, let's set our diagnostic headers
-	r.Header[http.CanonicalHeaderKey("Trout-Methods")] = route.methods
-	r.Header.Set("Trout-Pattern", route.pattern)
-	for key, vals := range route.params {
-		r.Header[http.CanonicalHeaderKey("Trout-Param-"+key)] = vals
-		for _, val := range vals {
-			setBuiltinRequestPathVar(r, key, val)
+	if !router.DisableHeaders {
+		r.Header[http.CanonicalHeaderKey("Trout-Methods")] = append([]string(nil), route.methods...)
+		r.Header.Set("Trout-Pattern", route.pattern)
+	}
+	for _, p := range route.orderedParams {
+		if router.LegacyHeaderVars && !router.DisableHeaders {
+			key := http.CanonicalHeaderKey("Trout-Param-" + p.Name)
+			r.Header[key] = append(r.Header[key], p.Value)
 		}
+		setBuiltinRequestPathVar(r, p.Name, p.Value)
+	}
+	if route.prefix {
+		setBuiltinRequestPathVar(r, RemainderPathValue, route.remainder)
 	}
 
 	// if no handler is set, it could be because there's no handler for
 	// this endpoint, which we can safely assume is a 404
 	if route.handler == nil {
-		if len(route.methods) < 1 {
-			return router.get404()
+		if len(route.methods) < 1 || router.DisableMethodNotAllowed {
+			router.matched(r, "", false, http.StatusNotFound)
+			return router.get404(route.node), r
 		}
 		// but it could also mean that there's an endpoint that just
-		// doesn't support the method we used, which is a 405
-		return router.get405()
+		// doesn't support the method we used, which is a 405, unless
+		// we've been asked not to reveal the endpoint exists
+		router.matched(r, route.pattern, true, http.StatusMethodNotAllowed)
+		return router.get405(route.node), r
 	}
 
 	// apply any middleware on the route
@@ -254,15 +918,159 @@ func (router Router) getHandler(r *http.Request) http.Handler {
 		handler = route.middleware[i](handler)
 	}
 
+	// if we're serving a HEAD request with a GET handler, throw away
+	// whatever body it writes
+	if route.head {
+		handler = headHandler(handler)
+	}
+
 	// after all that, if we still haven't found a problem, use the handler
 	// we have
-	return handler
+	router.matched(r, route.pattern, true, http.StatusOK)
+	return handler, r
+}
+
+// matched calls the Router's OnMatch function, if it has one, with the
+// routing decision made for `r`.
+func (router Router) matched(r *http.Request, pattern string, matched bool, status int) {
+	if router.OnMatch != nil {
+		router.OnMatch(r, pattern, matched, status)
+	}
+}
+
+// cleanPath returns `p` with any repeated `/` characters collapsed and any
+// `.` and `..` path elements resolved, like path.Clean, but keeps a trailing
+// `/` if `p` had one.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if cleaned != "/" && strings.HasSuffix(p, "/") {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// recordTiming stores how long it's been since `start` on `info`, and in the
+// Trout-Timer header of `r` if `header` is true.
+func recordTiming(r *http.Request, info *route, start time.Time, header bool) {
+	info.elapsed = time.Since(start)
+	if header {
+		r.Header.Set("Trout-Timer", strconv.FormatInt(info.elapsed.Nanoseconds(), 10))
+	}
+}
+
+// allowHeader returns the value of an Allow header for `methods`, which will
+// be sorted and de-duplicated, and always include OPTIONS. The catch-all
+// method is never included.
+func allowHeader(methods []string) string {
+	return joinMethods(methods, http.MethodOptions)
+}
+
+// joinMethods returns `methods` and `extra`, sorted, de-duplicated, and
+// separated by commas, for use in an Allow header. The catch-all method is
+// never included.
+func joinMethods(methods []string, extra ...string) string {
+	return strings.Join(sortMethods(methods, extra...), ", ")
+}
+
+// sortMethods returns `methods` and `extra` in a new slice, sorted and
+// de-duplicated. The catch-all method is never included.
+func sortMethods(methods []string, extra ...string) []string {
+	allowed := make([]string, 0, len(methods)+len(extra))
+	allowed = append(allowed, extra...)
+	for _, method := range methods {
+		if method == catchAllMethod {
+			continue
+		}
+		allowed = append(allowed, method)
+	}
+	if len(allowed) < 1 {
+		return allowed
+	}
+	sort.Strings(allowed)
+	deduped := allowed[:1]
+	for _, method := range allowed[1:] {
+		if method != deduped[len(deduped)-1] {
+			deduped = append(deduped, method)
+		}
+	}
+	return deduped
+}
+
+// hasExplicitMethods returns true if `n` has a handler set for any method
+// other than the catch-all method.
+func hasExplicitMethods(n *node) bool {
+	for method := range n.methods {
+		if method != catchAllMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// optionsHandler returns an http.Handler that responds to OPTIONS requests
+// with a 204 and an Allow header listing `methods`.
+func optionsHandler(methods []string) http.Handler {
+	allow := allowHeader(methods)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// trailingSlashRedirect returns an http.Handler that redirects `r` to `path`,
+// the escaped path it was routed using, with a trailing slash added, if
+// `slash` is true, or removed, if `slash` is false. The query string is
+// preserved, and methods other than GET and HEAD are redirected with a 308 so
+// the method is preserved. The target always starts with exactly one `/`, so
+// a path like `//example.com/` can't turn it into a protocol-relative URL
+// pointing at another host.
+func trailingSlashRedirect(r *http.Request, path string, slash bool) http.Handler {
+	target := strings.TrimSuffix(path, "/")
+	// browsers treat \ like /, so neither can start the target
+	target = "/" + strings.TrimLeft(target, `/\`)
+	if target == "/" {
+		slash = false
+	}
+	if slash {
+		target += "/"
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	return http.RedirectHandler(target, redirectStatus(r.Method))
+}
+
+// redirectStatus returns the status code trailing slash redirects use for
+// requests using `method`: a 301 for GET and HEAD requests, and a 308 for all
+// other methods, so the method and body are preserved.
+func redirectStatus(method string) int {
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
 }
 
 // ServeHTTP finds the best handler for the request, using the 404 or 405
 // handlers if necessary, and serves the request.
 func (router Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	handler := router.getHandler(r)
+	if len(router.preMiddleware) < 1 {
+		router.serveRouted(w, r)
+		return
+	}
+	var handler http.Handler = http.HandlerFunc(router.serveRouted)
+	for i := len(router.preMiddleware) - 1; i >= 0; i-- {
+		handler = router.preMiddleware[i](handler)
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// serveRouted routes `r` and serves it with the handler that was found,
+// wrapped in any Router middleware.
+func (router Router) serveRouted(w http.ResponseWriter, r *http.Request) {
+	handler, r := router.getHandler(r)
 	for i := len(router.middleware) - 1; i >= 0; i-- {
 		handler = router.middleware[i](handler)
 	}
@@ -292,6 +1100,48 @@ func (router *Router) SetMiddleware(mw ...func(http.Handler) http.Handler) {
 	router.middleware = mw
 }
 
+// AddMiddleware adds one or more middleware functions after any middleware
+// already set using SetMiddleware or AddMiddleware, so they'll run after
+// them. For example, if router.SetMiddleware(A) and router.AddMiddleware(B, C)
+// are called, trout will call A(B(C(handler))).
+func (router *Router) AddMiddleware(mw ...func(http.Handler) http.Handler) {
+	router.middleware = appendMiddleware(router.middleware, mw)
+}
+
+// ClearMiddleware removes all the middleware set using SetMiddleware or
+// AddMiddleware.
+func (router *Router) ClearMiddleware() {
+	router.middleware = nil
+}
+
+// appendMiddleware returns a new slice holding `existing` followed by `mw`,
+// so that appending never modifies a slice that's already in use.
+func appendMiddleware(existing, mw []func(http.Handler) http.Handler) []func(http.Handler) http.Handler {
+	res := make([]func(http.Handler) http.Handler, 0, len(existing)+len(mw))
+	res = append(res, existing...)
+	return append(res, mw...)
+}
+
+// SetPreMiddleware sets one or more middleware functions that will run
+// before the request is routed. Pre-middleware is the outermost layer of the
+// Router: it runs before any routing work is done and before any Trout-
+// headers are set, then the request is routed, then the middleware set using
+// SetMiddleware runs, followed by any route-specific middleware and the route
+// handler. This makes it the right place for things like panic recovery or
+// redirecting to HTTPS, which should apply to every request, whether or not
+// it matches.
+//
+// Because the request hasn't been routed yet, pre-middleware can't use
+// RequestVars, Pattern, or the other functions that read routing
+// information. Any Trout- headers it sets on the request will be removed
+// before routing.
+//
+// Pre-middleware is applied in the order it appears in the SetPreMiddleware
+// call, just like SetMiddleware.
+func (router *Router) SetPreMiddleware(mw ...func(http.Handler) http.Handler) {
+	router.preMiddleware = mw
+}
+
 // Endpoint defines a single URL template that requests can be matched against.
 // It is only valid to instantiate an Endpoint by calling `Router.Endpoint`.
 // Endpoints, on their own, are only useful for calling their methods, as they
@@ -303,59 +1153,257 @@ type Endpoint node
 // runtime. For example, `{id}` denotes a parameter named `id` that should be
 // filled with whatever the request has in that space.
 //
-// Parameters are always `/`-separated strings. There is no support for regular
-// expressions or other limitations on what may be in those strings. A
-// parameter is simply defined as "whatever is between these two / characters".
+// Parameters are always `/`-separated strings. By default, there are no
+// limitations on what may be in those strings, and a parameter is simply
+// defined as "whatever is between these two / characters". Requests are
+// split on the `/` characters in their escaped path, so an escaped `/`
+// (`%2F`) will be part of a parameter's value, not the end of it.
 //
-// Endpoints are always case-insensitive and coerced to lowercase. Endpoints
+// A parameter can optionally be constrained with a regular expression, by
+// following its name with a `:` and the expression, like `{id:[0-9]+}`. The
+// expression must match the entire path element for the Endpoint to match.
+// The shortcuts `int`, `uuid`, and `slug` can be used in place of an
+// expression for those common cases, like `{id:int}`. Constrained parameters
+// will be preferred over unconstrained parameters when both match.
+//
+// The exception is a parameter that ends in `...`, like `{path...}`, which
+// will capture every remaining path element, joined by `/`. These catch-all
+// parameters should only be used as the last element of the Endpoint. A
+// constraint on a catch-all parameter, like `{path...:.*\.css}`, has to
+// match the whole value it captures.
+//
+// The static path elements of Endpoints are case-insensitive and coerced to
+// lowercase, unless the Router's CaseSensitive property is set. Parameter
+// names and the values they're filled with always keep their case. Endpoints
 // will only match requests with URLs that match the entire Endpoint and have
 // no extra path elements.
+//
+// Endpoint panics if `e` is not a valid URL template. Use AddEndpoint to get
+// an error instead.
 func (router *Router) Endpoint(e string) *Endpoint {
+	endpoint, err := router.AddEndpoint(e)
+	if err != nil {
+		panic(err)
+	}
+	return endpoint
+}
+
+// AddEndpoint defines a new Endpoint on the Router, exactly like Endpoint,
+// but returns an error instead of panicking if `e` is not a valid URL
+// template.
+func (router *Router) AddEndpoint(e string) (*Endpoint, error) {
+	keys, err := keysFromString(e, router.CaseSensitive)
+	if err != nil {
+		return nil, err
+	}
+	return (*Endpoint)(router.add(keys, len(e) > 1 && strings.HasSuffix(e, "/"))), nil
+}
+
+// add inserts `keys` into the Router's trie, creating the trie if necessary,
+// and returns the terminating node for `keys`. `trailingSlash` is recorded on
+// the terminating node if it didn't already exist.
+func (router *Router) add(keys []key, trailingSlash bool) *node {
 	if router.trie == nil {
-		router.trie = &trie{
-			root: &node{
-				children: map[string]*node{},
-			},
-		}
+		router.trie = newTrie()
 	}
-	keys := keysFromString(e)
-	node := router.trie.add(keys, map[string]http.Handler{})
-	return (*Endpoint)(node)
+	n, created := router.trie.add(keys)
+	if created {
+		n.trailingSlash = trailingSlash
+	}
+	return n
+}
+
+// constraintShortcuts holds the named regular expressions that can be used
+// in place of a full regular expression when constraining a parameter.
+var constraintShortcuts = map[string]string{
+	"int":  `-?[0-9]+`,
+	"uuid": `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	"slug": `[a-z0-9]+(-[a-z0-9]+)*`,
 }
 
-// keysFromString parses `in` and returns the keys that represent it.
-func keysFromString(in string) []key {
+// enumRE matches constraints that are a list of allowed values separated by
+// "|", like `active|inactive`, rather than a regular expression.
+var enumRE = regexp.MustCompile(`^[\w.~-]*(\|[\w.~-]*)+$`)
+
+// keysFromString parses `in` and returns the keys that represent it. An
+// error is returned if `in` isn't a valid URL template: if its braces aren't
+// balanced, if a parameter has no name or an invalid constraint, or if a
+// catch-all parameter isn't the last path element.
+//
+// Unless `caseSensitive` is true, the static path elements, the literal text
+// around parameters, and lists of allowed values are lowercased, so they can
+// be matched case-insensitively.
+func keysFromString(in string, caseSensitive bool) ([]key, error) {
+	fold := strings.ToLower
+	if caseSensitive {
+		fold = func(s string) string { return s }
+	}
 	in = strings.Trim(in, "/")
 	pieces := strings.Split(in, "/")
 	keys := make([]key, 0, len(pieces))
-	for _, piece := range pieces {
+	for i, piece := range pieces {
 		k := key{
-			value: piece,
+			value:         fold(piece),
+			caseSensitive: caseSensitive,
 		}
-		if strings.HasPrefix(piece, "{") && strings.HasSuffix(piece, "}") {
+		start, end, ok := findParam(piece)
+		if !ok {
+			return nil, fmt.Errorf("trout: unbalanced braces in path element %q of %q", piece, in)
+		}
+		if start < 0 {
+			// an escaped literal brace, not a parameter
+			unescaped, _ := unescapeBraces(piece)
+			k.value = fold(unescaped)
+		} else {
+			before, beforeOK := unescapeBraces(piece[:start])
+			after, afterOK := unescapeBraces(piece[end+1:])
+			if !beforeOK || !afterOK {
+				if next, _, _ := findParam(piece[end+1:]); next >= 0 {
+					return nil, fmt.Errorf("trout: more than one parameter in path element %q of %q", piece, in)
+				}
+				return nil, fmt.Errorf("trout: unbalanced braces in path element %q of %q", piece, in)
+			}
 			k.dynamic = true
-			k.value = piece[1 : len(piece)-1]
+			k.before = fold(before)
+			k.after = fold(after)
+			k.value = piece[start+1 : end]
+			if name, constraint, ok := strings.Cut(k.value, ":"); ok && enumRE.MatchString(constraint) {
+				for _, val := range strings.Split(constraint, "|") {
+					if val == "" {
+						return nil, fmt.Errorf("trout: empty value in the list of values for parameter %q in %q", name, in)
+					}
+					k.enum = append(k.enum, fold(val))
+				}
+				k.value = name
+				k.constraint = constraint
+			} else if ok {
+				expr := constraint
+				if shortcut, ok := constraintShortcuts[constraint]; ok {
+					expr = shortcut
+				}
+				re, err := regexp.Compile("^(?:" + expr + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("trout: invalid constraint for parameter %q in %q: %w", name, in, err)
+				}
+				k.value = name
+				k.constraint = constraint
+				k.re = re
+			}
+			if strings.HasSuffix(k.value, "...") {
+				k.catchAll = true
+				k.value = strings.TrimSuffix(k.value, "...")
+			}
+			if k.value == "" {
+				return nil, fmt.Errorf("trout: empty parameter name in path element %q of %q", piece, in)
+			}
+			if strings.ContainsAny(k.value, "{}") {
+				return nil, fmt.Errorf("trout: unbalanced braces in path element %q of %q", piece, in)
+			}
+			if k.catchAll && i != len(pieces)-1 {
+				return nil, fmt.Errorf("trout: catch-all parameter %q must be the last path element of %q", k.value, in)
+			}
+			if k.catchAll && k.mixed() {
+				return nil, fmt.Errorf("trout: catch-all parameter %q can't share path element %q of %q with other text", k.value, piece, in)
+			}
 		}
 		keys = append(keys, k)
 	}
-	return keys
+	return keys, nil
+}
+
+// findParam returns the positions of the braces around the first parameter
+// in `piece`. If `piece` has no parameter, start is -1. Escaped braces, `{{`
+// and `}}`, are skipped outside of parameters; inside a parameter, braces
+// are counted so constraints can use them. If `piece` has a closing brace
+// that isn't escaped or a parameter that isn't closed, ok is false.
+func findParam(piece string) (start, end int, ok bool) {
+	for i := 0; i < len(piece); i++ {
+		c := piece[i]
+		if c != '{' && c != '}' {
+			continue
+		}
+		if i+1 < len(piece) && piece[i+1] == c {
+			i++
+			continue
+		}
+		if c == '}' {
+			return -1, -1, false
+		}
+		depth := 0
+		for j := i; j < len(piece); j++ {
+			switch piece[j] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return i, j, true
+				}
+			}
+		}
+		return -1, -1, false
+	}
+	return -1, -1, true
+}
+
+// unescapeBraces returns `piece` with each `{{` and `}}` replaced by a literal
+// `{` or `}`. If `piece` has a brace that isn't escaped, false is returned.
+func unescapeBraces(piece string) (string, bool) {
+	if !strings.ContainsAny(piece, "{}") {
+		return piece, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(piece); i++ {
+		c := piece[i]
+		if c == '{' || c == '}' {
+			if i+1 >= len(piece) || piece[i+1] != c {
+				return "", false
+			}
+			i++
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), true
 }
 
 // Handler sets the default http.Handler for `e`, to be used for all requests
 // that `e` matches that don't match a method explicitly set for `e` using the
-// Methods method.
+// Methods method. It returns `e`, so more methods can be chained.
 //
 // Handler is not concurrency-safe, and should not be used while the Router `e`
 // belongs to is actively routing traffic.
-func (e *Endpoint) Handler(h http.Handler) {
-	(*node)(e).methods[catchAllMethod] = h
+func (e *Endpoint) Handler(h http.Handler) *Endpoint {
+	(*node)(e).setMethod(catchAllMethod, h)
+	return e
 }
 
-// Middleware sets one or more middleware functions that will wrap the default
-// http.Handler for `e`, to be used for all requests that `e` matches that
-// don't match a method explicitly set for `e` using the Methods method.
-// Middleware will run after routing, after any Router middleware, but before
-// the route handler.
+// HandlerFunc sets `h` as the default handler for `e`, exactly like Handler.
+func (e *Endpoint) HandlerFunc(h func(http.ResponseWriter, *http.Request)) *Endpoint {
+	return e.Handler(http.HandlerFunc(h))
+}
+
+// Handlers sets the http.Handler for each method in `handlers` on `e`, as
+// though Methods(method).Handler(handler) had been called for each of them.
+// A "*" key sets the default handler for `e`, like Handler. It returns `e`, so
+// more methods can be chained.
+//
+// Handlers is not concurrency-safe, and should not be used while the Router
+// `e` belongs to is actively routing traffic.
+func (e *Endpoint) Handlers(handlers map[string]http.Handler) *Endpoint {
+	setHandlers((*node)(e), handlers)
+	return e
+}
+
+// Middleware sets one or more middleware functions that will wrap every
+// http.Handler for `e`: the default one, and the ones set for specific methods
+// using the Methods method, whose own middleware runs inside the Endpoint's.
+// So if Endpoint.Middleware(A) and Methods("GET").Middleware(B) are both
+// called, trout will call A(B(handler)) for GET requests, and A(handler) for
+// requests served by the default handler. Middleware will run after routing,
+// after any Router middleware, but before the route handler. Because the
+// request has been routed, middleware can use RequestVars, Pattern, and the
+// other functions that read routing information, and can reject a request by
+// responding to it without calling the handler.
 //
 // Middleware is applied in the order it appears in the Middleware call. So,
 // for example, if Endpoint.SetMiddleware(A, B, C) is called, trout will call
@@ -365,6 +1413,36 @@ func (e *Endpoint) Middleware(mw ...func(http.Handler) http.Handler) *Endpoint {
 	return e
 }
 
+// AddMiddleware adds one or more middleware functions after any middleware
+// already set on the default http.Handler for `e`, following the same rules
+// as Router.AddMiddleware.
+func (e *Endpoint) AddMiddleware(mw ...func(http.Handler) http.Handler) *Endpoint {
+	n := (*node)(e)
+	n.middleware[catchAllMethod] = appendMiddleware(n.middleware[catchAllMethod], mw)
+	return e
+}
+
+// ClearMiddleware removes all the middleware set on the default http.Handler
+// for `e`.
+func (e *Endpoint) ClearMiddleware() *Endpoint {
+	delete((*node)(e).middleware, catchAllMethod)
+	return e
+}
+
+// Priority sets the priority of `e`, which is 0 by default. When more than one
+// Endpoint or Prefix matches a request, the one with the highest priority is
+// used, no matter how specific the others are. Endpoints and Prefixes that
+// have a handler for the request's method are always used before those that
+// don't, regardless of priority. Priority can be negative, to make `e` lose
+// to Endpoints and Prefixes it would otherwise beat.
+//
+// Priority is not concurrency-safe, and should not be used while the Router
+// `e` belongs to is actively routing traffic.
+func (e *Endpoint) Priority(priority int) *Endpoint {
+	(*node)(e).priority = priority
+	return e
+}
+
 // Prefix defines a URL template that requests can be matched against. It is
 // only valid to instantiate a prefix by calling `Router.Prefix`. Prefixes, on
 // their own, are only useful for calling their methods, as they don't do
@@ -380,45 +1458,79 @@ type Prefix node
 // runtime. For example, `{id}` denotes a parameter named `id` that should be
 // filled with whatever the request has in that space.
 //
-// Parameters are always `/`-separated strings. There is no support for regular
-// expressions or other limitations on what may be in those strings. A
-// parameter is simply defined as "whatever is between these two / characters".
+// Parameters follow the same rules as they do for Endpoints, including
+// optional constraints.
 //
-// Prefixes are always case-insensitive and coerced to lowercase. Prefixes will
-// only match requests with URLs that match the entire Prefix, but the URL may
-// have additional path elements after the Prefix and still be considered a
-// match.
+// The static path elements of Prefixes are case-insensitive and coerced to
+// lowercase unless the Router's CaseSensitive property is set, just like
+// Endpoints. Prefixes will only match requests with URLs that match the
+// entire Prefix, but the URL may have additional path elements after the
+// Prefix and still be considered a match. When more than one Prefix matches
+// a request, the one that matches the most path elements wins, and any
+// Endpoint that matches more of the path than a Prefix wins over it.
+//
+// Prefix panics if `p` is not a valid URL template. Use AddPrefix to get an
+// error instead.
 func (router *Router) Prefix(p string) *Prefix {
-	if router.trie == nil {
-		router.trie = &trie{
-			root: &node{
-				children: map[string]*node{},
-			},
-		}
+	prefix, err := router.AddPrefix(p)
+	if err != nil {
+		panic(err)
+	}
+	return prefix
+}
+
+// AddPrefix defines a new Prefix on the Router, exactly like Prefix, but
+// returns an error instead of panicking if `p` is not a valid URL template.
+func (router *Router) AddPrefix(p string) (*Prefix, error) {
+	keys, err := keysFromString(p, router.CaseSensitive)
+	if err != nil {
+		return nil, err
 	}
-	keys := keysFromString(p)
 	last := keys[len(keys)-1]
 	last.prefix = true
 	keys[len(keys)-1] = last
-	node := router.trie.add(keys, map[string]http.Handler{})
-	return (*Prefix)(node)
+	return (*Prefix)(router.add(keys, false)), nil
 }
 
 // Handler sets the default http.Handler for `p`, to be used for all requests
 // that `p` matches that don't match a method explicitly set for `p` using the
-// Methods method.
+// Methods method. It returns `p`, so more methods can be chained.
 //
 // Handler is not concurrency-safe, and should not be used while the Router `p`
 // belongs to is actively routing traffic.
-func (p *Prefix) Handler(h http.Handler) {
-	(*node)(p).methods[catchAllMethod] = h
+func (p *Prefix) Handler(h http.Handler) *Prefix {
+	(*node)(p).setMethod(catchAllMethod, h)
+	return p
+}
+
+// HandlerFunc sets `h` as the default handler for `p`, exactly like Handler.
+func (p *Prefix) HandlerFunc(h func(http.ResponseWriter, *http.Request)) *Prefix {
+	return p.Handler(http.HandlerFunc(h))
+}
+
+// Handlers sets the http.Handler for each method in `handlers` on `p`,
+// exactly like Endpoint.Handlers.
+func (p *Prefix) Handlers(handlers map[string]http.Handler) *Prefix {
+	setHandlers((*node)(p), handlers)
+	return p
 }
 
-// Middleware sets one or more middleware functions that will wrap the default
-// http.Handler for `p`, to be used for all requests that `p` matches that
-// don't match a method explicitly set for `e` using the Methods method.
-// Middleware will run after routing, after any Router middleware, but before
-// the route handler.
+// setHandlers sets the http.Handler for each method in `handlers` on `n`,
+// converting the methods to uppercase and ignoring empty ones.
+func setHandlers(n *node, handlers map[string]http.Handler) {
+	for method, h := range handlers {
+		if method = normalizeMethod(method); method != "" {
+			n.setMethod(method, h)
+		}
+	}
+}
+
+// Middleware sets one or more middleware functions that will wrap every
+// http.Handler for `p`, with the middleware of handlers set for specific
+// methods running inside it, exactly like Endpoint.Middleware. Middleware
+// will run after routing, after any Router middleware, but before
+// the route handler, so it can read routing information and reject requests
+// just like Endpoint middleware.
 //
 // Middleware is applied in the order it appears in the Middleware call. So,
 // for example, if Prefix.SetMiddleware(A, B, C) is called, trout will call
@@ -428,6 +1540,33 @@ func (p *Prefix) Middleware(mw ...func(http.Handler) http.Handler) *Prefix {
 	return p
 }
 
+// AddMiddleware adds one or more middleware functions after any middleware
+// already set on the default http.Handler for `p`, following the same rules
+// as Router.AddMiddleware.
+func (p *Prefix) AddMiddleware(mw ...func(http.Handler) http.Handler) *Prefix {
+	n := (*node)(p)
+	n.middleware[catchAllMethod] = appendMiddleware(n.middleware[catchAllMethod], mw)
+	return p
+}
+
+// ClearMiddleware removes all the middleware set on the default http.Handler
+// for `p`.
+func (p *Prefix) ClearMiddleware() *Prefix {
+	delete((*node)(p).middleware, catchAllMethod)
+	return p
+}
+
+// Priority sets the priority of `p`, which is 0 by default, following the same
+// rules as Endpoint.Priority. This can be used to make a Prefix win over a
+// more specific Endpoint.
+//
+// Priority is not concurrency-safe, and should not be used while the Router
+// `p` belongs to is actively routing traffic.
+func (p *Prefix) Priority(priority int) *Prefix {
+	(*node)(p).priority = priority
+	return p
+}
+
 // Methods defines a pairing of an Endpoint to HTTP request methods, to map
 // designate specific http.Handlers for requests matching that Endpoint made
 // using the specified methods. It is only valid to instantiate Methods by
@@ -437,16 +1576,29 @@ func (p *Prefix) Middleware(mw ...func(http.Handler) http.Handler) *Prefix {
 type Methods struct {
 	n *node
 	m []string
+	// except is set when `m` holds the methods the catch-all handler
+	// shouldn't serve, instead of the methods to set handlers for
+	except bool
 }
 
 // Methods returns a Methods object that will enable the mapping of the passed
 // HTTP request methods to the Endpoint. On its own, this function does not
 // modify anything. It should, instead, be used as a friendly shorthand to get
 // to the Methods.Handler method.
+//
+// Method names are case-sensitive in HTTP, and the standard methods are
+// always uppercase, so the passed methods are converted to uppercase. Any
+// other method, like the WebDAV methods MKCOL and PROPFIND, can be used, and
+// is routed and reported in the Allow and Trout-Methods headers just like
+// the standard methods. Empty methods are ignored.
+//
+// The method "*" stands for any method, and sets the default handler for the
+// Endpoint, just like Endpoint.Handler and Endpoint.Any. Handlers set for
+// specific methods always win over it for requests using those methods.
 func (e *Endpoint) Methods(m ...string) Methods {
 	return Methods{
 		n: (*node)(e),
-		m: m,
+		m: normalizeMethods(m),
 	}
 }
 
@@ -454,11 +1606,69 @@ func (e *Endpoint) Methods(m ...string) Methods {
 // HTTP request methods to the Prefix. On its own, this function does not
 // modify anything. It should, instead, be used as a friendly shorthand to get
 // to the Methods.Handler method.
+//
+// The passed methods are converted to uppercase, just like Endpoint.Methods.
 func (p *Prefix) Methods(m ...string) Methods {
 	return Methods{
 		n: (*node)(p),
-		m: m,
+		m: normalizeMethods(m),
+	}
+}
+
+// MethodsExcept returns a Methods object that will enable the mapping of every
+// HTTP request method except the passed methods to the Endpoint. Its Handler
+// method sets the default handler for the Endpoint, like Endpoint.Handler,
+// but requests using the passed methods won't be served by it, and will get a
+// 405 response instead, unless a handler is set for them using
+// Endpoint.Methods. The standard HTTP methods that aren't excluded are listed
+// in the Allow header of the 405 response.
+//
+// The passed methods are converted to uppercase, just like Endpoint.Methods.
+func (e *Endpoint) MethodsExcept(m ...string) Methods {
+	return Methods{
+		n:      (*node)(e),
+		m:      normalizeMethods(m),
+		except: true,
+	}
+}
+
+// MethodsExcept returns a Methods object that will enable the mapping of every
+// HTTP request method except the passed methods to the Prefix, exactly like
+// Endpoint.MethodsExcept.
+func (p *Prefix) MethodsExcept(m ...string) Methods {
+	return Methods{
+		n:      (*node)(p),
+		m:      normalizeMethods(m),
+		except: true,
+	}
+}
+
+// keys returns the keys in the methods and middleware maps of the node `m`
+// sets handlers and middleware for.
+func (m Methods) keys() []string {
+	if m.except {
+		return []string{catchAllMethod}
+	}
+	return m.m
+}
+
+// normalizeMethods returns a copy of `methods` with each method converted to
+// uppercase, using normalizeMethod, and empty methods removed.
+func normalizeMethods(methods []string) []string {
+	normalized := make([]string, 0, len(methods))
+	for _, method := range methods {
+		if method = normalizeMethod(method); method != "" {
+			normalized = append(normalized, method)
+		}
 	}
+	return normalized
+}
+
+// normalizeMethod returns `method` without surrounding whitespace, converted
+// to uppercase. Any other method, including extension methods like MKCOL or
+// PROPFIND, is kept as it is.
+func normalizeMethod(method string) string {
+	return strings.ToUpper(strings.TrimSpace(method))
 }
 
 // Handler associates an http.Handler with the Endpoint associated with `m`, to
@@ -468,23 +1678,57 @@ func (p *Prefix) Methods(m ...string) Methods {
 // Handler is not concurrency-safe. It should not be called while the Router
 // that owns the Endpoint that `m` belongs to is actively serving traffic.
 func (m Methods) Handler(h http.Handler) {
-	for _, method := range m.m {
-		m.n.methods[method] = h
+	if m.except {
+		m.n.excludedMethods = map[string]bool{}
+		for _, method := range m.m {
+			m.n.excludedMethods[method] = true
+		}
+	}
+	for _, method := range m.keys() {
+		m.n.setMethod(method, h)
 	}
 }
 
+// HandlerFunc associates `h` with the Endpoint associated with `m`, exactly
+// like Handler.
+func (m Methods) HandlerFunc(h func(http.ResponseWriter, *http.Request)) {
+	m.Handler(http.HandlerFunc(h))
+}
+
 // Middleware sets one or more middleware functions that will wrap the
 // http.Handler associated with `m`, to be used whenever a request that matches
 // the Endpoint also matches one of the Methods associated with m. Middleware
-// will run after routing, after any Router middleware, but before the route
-// handler.
+// will run after routing, after any Router middleware and any middleware set
+// on the Endpoint or Prefix itself, but before the route handler, so
+// RequestVars, Pattern, and the other functions that read routing information
+// work inside it, and it can respond to a request itself, without calling the
+// handler, to reject it.
 //
 // Middleware is applied in the order it appears in the Middleware call. So,
 // for example, if Methods.SetMiddleware(A, B, C) is called, trout will call
 // A(B(C(handler))) when calling the Methods' handler.
 func (m Methods) Middleware(mw ...func(http.Handler) http.Handler) Methods {
-	for _, method := range m.m {
+	for _, method := range m.keys() {
 		m.n.middleware[method] = mw
 	}
 	return m
 }
+
+// AddMiddleware adds one or more middleware functions after any middleware
+// already set on the http.Handler for each of the methods of `m`, following
+// the same rules as Router.AddMiddleware.
+func (m Methods) AddMiddleware(mw ...func(http.Handler) http.Handler) Methods {
+	for _, method := range m.keys() {
+		m.n.middleware[method] = appendMiddleware(m.n.middleware[method], mw)
+	}
+	return m
+}
+
+// ClearMiddleware removes all the middleware set on the http.Handler for each
+// of the methods of `m`.
+func (m Methods) ClearMiddleware() Methods {
+	for _, method := range m.keys() {
+		delete(m.n.middleware, method)
+	}
+	return m
+}
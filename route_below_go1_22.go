@@ -6,3 +6,7 @@ import "net/http"
 
 func setBuiltinRequestPathVar(_ *http.Request, _, _ string) {
 }
+
+func builtinRequestPathVar(_ *http.Request, _ string) string {
+	return ""
+}
@@ -145,6 +145,47 @@ func ExampleMethods_Handler() {
 	// 405 Method Not Allowed
 }
 
+func ExampleMethods_Middleware() {
+	postsHandler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte("matched " + trout.RequestVars(r).Get("owner")))
+			if err != nil {
+				panic(err)
+			}
+		})
+
+	// route middleware runs after the request has been routed, so it
+	// can use the parameters of the request to decide whether to let it
+	// through
+	onlyAlice := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trout.RequestVars(r).Get("owner") != "alice" {
+				w.WriteHeader(http.StatusForbidden)
+				_, err := w.Write([]byte("403 Forbidden"))
+				if err != nil {
+					panic(err)
+				}
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	var router trout.Router
+	router.Endpoint("/users/{owner}/posts").Methods("GET").Middleware(onlyAlice).Handler(postsHandler)
+
+	req, _ := http.NewRequest("GET", "http://example.com/users/alice/posts", nil)
+	router.ServeHTTP(exampleResponseWriter{}, req)
+
+	// this will return a 403, without postsHandler ever running
+	req, _ = http.NewRequest("GET", "http://example.com/users/bob/posts", nil)
+	router.ServeHTTP(exampleResponseWriter{}, req)
+
+	// Output:
+	// matched alice
+	// 403 Forbidden
+}
+
 func ExampleRequestVars() {
 	postsHandler := http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
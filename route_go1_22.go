@@ -7,3 +7,7 @@ import "net/http"
 func setBuiltinRequestPathVar(r *http.Request, name, value string) {
 	r.SetPathValue(name, value)
 }
+
+func builtinRequestPathVar(r *http.Request, name string) string {
+	return r.PathValue(name)
+}
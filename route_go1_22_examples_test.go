@@ -29,3 +29,25 @@ func ExampleRouter_Endpoint_pathValues() {
 	// Output:
 	// foo
 }
+
+func ExampleRouter_Prefix_pathValues() {
+	filesHandler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			// the part of the path after the Prefix is available
+			// as a path value too.
+			path := r.PathValue("id") + ": " + r.PathValue(trout.RemainderPathValue)
+			_, err := w.Write([]byte(path))
+			if err != nil {
+				panic(err)
+			}
+		})
+
+	var router trout.Router
+	router.Prefix("/files/{id}").Handler(filesHandler)
+
+	req, _ := http.NewRequest("GET", "http://example.com/files/foo/a/b/c", nil)
+	router.ServeHTTP(exampleResponseWriter{}, req)
+
+	// Output:
+	// foo: a/b/c
+}
@@ -0,0 +1,33 @@
+//go:build go1.22
+
+package trout
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPathValueCase(t *testing.T) {
+	type testCase struct {
+		url, slug, name string
+	}
+	cases := []testCase{
+		{"/Posts/Hello-World/files/IMG-Logo.png", "Hello-World", "Logo"},
+		{"/posts/hello-world/FILES/img-LOGO.png", "hello-world", "LOGO"},
+	}
+	var router Router
+	router.Endpoint("/posts/{slug}/files/img-{name}.png").Handler(testHandler("file"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		_, r = router.getHandler(r)
+		if slug := r.PathValue("slug"); slug != c.slug {
+			t.Errorf("Expected slug for %s to be %q, got %q", c.url, c.slug, slug)
+		}
+		if name := r.PathValue("name"); name != c.name {
+			t.Errorf("Expected name for %s to be %q, got %q", c.url, c.name, name)
+		}
+	}
+}
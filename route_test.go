@@ -5,8 +5,11 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
-	"time"
 )
 
 type testHandler string
@@ -38,6 +41,9 @@ func TestRouting(t *testing.T) {
 		{"/prefix/static", "GET", "static-prefix", "/prefix/static::prefix"},
 		{"/prefix/static/bar/baz", "GET", "static-prefix", "/prefix/static::prefix"},
 		{"/prefix", "GET", "get-dynamic", "/{id}"},
+		{"/files/a/b/c", "GET", "get-files", "/files/{rest...}"},
+		{"/files/a", "GET", "get-files", "/files/{rest...}"},
+		{"/files/static/exact", "GET", "static-files", "/files/static/exact"},
 	}
 	var router Router
 	router.Handle404 = testHandler("404")
@@ -51,12 +57,14 @@ func TestRouting(t *testing.T) {
 	router.Endpoint("/ancestor/one").Methods("GET").Handler(testHandler("ancestor-one"))
 	router.Endpoint("/ancestor/two").Methods("GET").Handler(testHandler("ancestor-two"))
 	router.Endpoint("/hello/world").Handler(testHandler("catch-all"))
+	router.Endpoint("/files/{rest...}").Methods("GET").Handler(testHandler("get-files"))
+	router.Endpoint("/files/static/exact").Methods("GET").Handler(testHandler("static-files"))
 	for _, c := range cases {
 		r, err := http.NewRequest(c.method, c.url, nil)
 		if err != nil {
 			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
 		}
-		h := router.getHandler(r)
+		h, r := router.getHandler(r)
 		res := string(h.(testHandler))
 		if res != c.handler {
 			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
@@ -72,6 +80,10 @@ func TestKeysFromString(t *testing.T) {
 		"/{id}/": []key{
 			{value: "id", dynamic: true},
 		},
+		"/files/{rest...}": []key{
+			{value: "files"},
+			{value: "rest", dynamic: true, catchAll: true},
+		},
 		"/v1": []key{
 			{value: "v1"},
 		},
@@ -86,67 +98,1568 @@ func TestKeysFromString(t *testing.T) {
 			{value: "ancestor"},
 			{value: "two"},
 		},
+		"/config/{{literal}}": []key{
+			{value: "config"},
+			{value: "{literal}"},
+		},
+		"/config/a{{b}}c/{id}": []key{
+			{value: "config"},
+			{value: "a{b}c"},
+			{value: "id", dynamic: true},
+		},
+		"/files/IMG-{name}.png": []key{
+			{value: "files"},
+			{value: "name", dynamic: true, before: "img-", after: ".png"},
+		},
+		"/orders/{status:open|closed}": []key{
+			{value: "orders"},
+			{value: "status", dynamic: true, constraint: "open|closed"},
+		},
+		"/v{version:int}": []key{
+			{value: "version", dynamic: true, before: "v", constraint: "int"},
+		},
+	}
+	for in, expect := range cases {
+		t.Logf("Testing case %s", in)
+		result, err := keysFromString(in, false)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %+v", in, err)
+			continue
+		}
+		if len(result) != len(expect) {
+			t.Errorf("Expected %d results, got %d: %+v", len(expect), len(result), result)
+			continue
+		}
+		for pos, res := range result {
+			if !res.equals(expect[pos]) {
+				t.Errorf("Expected result %d to be %+v, was %+v", pos, expect[pos], res)
+			}
+		}
+	}
+}
+
+func TestLiteralBraces(t *testing.T) {
+	type testCase struct {
+		url, handler string
+	}
+	cases := []testCase{
+		{"/config/{literal}", "literal"},
+		{"/config/%7Bliteral%7D", "literal"},
+		{"/config/{LITERAL}", "literal"},
+		{"/config/literal", "dynamic"},
+	}
+	var router Router
+	literal := router.Endpoint("/config/{{literal}}").Handler(testHandler("literal"))
+	router.Endpoint("/config/{name}").Handler(testHandler("dynamic"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+	}
+	if pattern := pathString((*node)(literal)); pattern != "/config/{{literal}}" {
+		t.Errorf("Expected pattern to round-trip as /config/{{literal}}, got %q", pattern)
+	}
+	if u, err := literal.URL(nil); err != nil || u != "/config/%7Bliteral%7D" {
+		t.Errorf("Expected URL to be /config/%%7Bliteral%%7D, got %q (%v)", u, err)
+	}
+}
+
+func TestEnumConstraints(t *testing.T) {
+	type testCase struct {
+		url, handler, status string
+	}
+	cases := []testCase{
+		{"/orders/open/items", "enum", "open"},
+		{"/orders/CLOSED/items", "enum", "CLOSED"},
+		{"/orders/pending/items", "fallback", ""},
+		{"/orders/open|closed/items", "fallback", ""},
+	}
+	var router Router
+	orders := router.Endpoint("/orders/{status:open|closed}/items").Handler(testHandler("enum"))
+	router.Endpoint("/orders/{id}/items").Handler(testHandler("fallback"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, r := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+		if status := RequestVars(r).Get("status"); status != c.status {
+			t.Errorf("Expected status for %s to be %q, got %q", c.url, c.status, status)
+		}
+	}
+	if u, err := orders.URL(map[string]string{"status": "open"}); err != nil || u != "/orders/open/items" {
+		t.Errorf("Expected URL to be /orders/open/items, got %q (%v)", u, err)
+	}
+	if _, err := orders.URL(map[string]string{"status": "pending"}); err == nil {
+		t.Errorf("Expected an error building a URL with a status that isn't allowed")
+	}
+}
+
+func TestCaseSensitive(t *testing.T) {
+	type testCase struct {
+		url, handler string
+	}
+	cases := []testCase{
+		{"/keys/aGVsbG8", "lower"},
+		{"/keys/AGVSBG8", "upper"},
+		{"/keys/AgvSbg8", "404"},
+		{"/KEYS/aGVsbG8", "404"},
+		{"/files/IMG-logo.png", "image"},
+		{"/files/img-logo.png", "404"},
+		{"/orders/Open", "order"},
+		{"/orders/open", "404"},
+	}
+	router := Router{CaseSensitive: true}
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/keys/aGVsbG8").Handler(testHandler("lower"))
+	router.Endpoint("/keys/AGVSBG8").Handler(testHandler("upper"))
+	router.Endpoint("/files/IMG-{name}.png").Handler(testHandler("image"))
+	router.Endpoint("/orders/{status:Open|Closed}").Handler(testHandler("order"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+	}
+}
+
+func TestSegmentMatcher(t *testing.T) {
+	type testCase struct {
+		url, handler string
+	}
+	cases := []testCase{
+		{"/blogposts/1", "post"},
+		{"/blog-posts/1", "post"},
+		{"/Blog-Posts/1", "post"},
+		{"/blog_posts/1", "404"},
+		{"/docs/getting-started/install", "install"},
+		{"/docs/getting-started/uninstall", "404"},
+		{"/docs/get-ting-start-ed/install", "install"},
+	}
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.SegmentMatcher = func(key, piece string) bool {
+		return key == strings.ReplaceAll(strings.ToLower(piece), "-", "")
+	}
+	router.Endpoint("/blogposts/{id}").Handler(testHandler("post"))
+	router.Endpoint("/docs/gettingstarted/install").Handler(testHandler("install"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+	}
+}
+
+func TestMixedSegments(t *testing.T) {
+	type testCase struct {
+		url, handler string
+		vars         map[string][]string
+	}
+	cases := []testCase{
+		{"/files/img-logo.png", "image", map[string][]string{"Name": {"logo"}}},
+		{"/files/IMG-Logo.PNG", "image", map[string][]string{"Name": {"Logo"}}},
+		{"/files/img-.png", "file", map[string][]string{"File": {"img-.png"}}},
+		{"/files/img-logo.gif", "file", map[string][]string{"File": {"img-logo.gif"}}},
+		{"/files/logo.png", "file", map[string][]string{"File": {"logo.png"}}},
+		{"/v2/users", "versioned", map[string][]string{"Version": {"2"}}},
+		{"/vx/users", "404", nil},
+	}
+	var router Router
+	router.Handle404 = testHandler("404")
+	image := router.Endpoint("/files/img-{name}.png").Handler(testHandler("image"))
+	router.Endpoint("/files/{file}").Handler(testHandler("file"))
+	router.Endpoint("/v{version:int}/users").Handler(testHandler("versioned"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, r := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+			continue
+		}
+		if c.vars == nil {
+			continue
+		}
+		if vars := RequestVars(r); !reflect.DeepEqual(map[string][]string(vars), c.vars) {
+			t.Errorf("Expected vars for %s to be %v, got %v", c.url, c.vars, vars)
+		}
+	}
+	if pattern := pathString((*node)(image)); pattern != "/files/img-{name}.png" {
+		t.Errorf("Expected pattern to be /files/img-{name}.png, got %q", pattern)
+	}
+	if u, err := image.URL(map[string]string{"name": "logo"}); err != nil || u != "/files/img-logo.png" {
+		t.Errorf("Expected URL to be /files/img-logo.png, got %q (%v)", u, err)
+	}
+}
+
+func TestCatchAllVars(t *testing.T) {
+	type testCase struct {
+		url  string
+		vars map[string][]string
+	}
+	cases := []testCase{
+		{"/files/a", map[string][]string{"Rest": {"a"}}},
+		{"/files/a/b/c", map[string][]string{"Rest": {"a/b/c"}}},
+		{"/users/foo/files/a/b/", map[string][]string{"Id": {"foo"}, "Rest": {"a/b"}}},
+		{"/users/a%2Fb/files/c%2Fd/e", map[string][]string{"Id": {"a/b"}, "Rest": {"c/d/e"}}},
+	}
+	var router Router
+	router.Endpoint("/files/{rest...}").Handler(testHandler("files"))
+	router.Endpoint("/users/{id}/files/{rest...}").Handler(testHandler("user-files"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		_, r = router.getHandler(r)
+		vars := RequestVars(r)
+		if len(vars) != len(c.vars) {
+			t.Errorf("Expected %d vars for %s, got %d: %+v", len(c.vars), c.url, len(vars), vars)
+		}
+		for k, v := range c.vars {
+			if strings.Join(vars[k], ",") != strings.Join(v, ",") {
+				t.Errorf("Expected %s to be %v for %s, got %v", k, v, c.url, vars[k])
+			}
+		}
+	}
+}
+
+func TestCatchAllConstraint(t *testing.T) {
+	type testCase struct {
+		url, handler string
+	}
+	cases := []testCase{
+		{"/assets/site.css", "css"},
+		{"/assets/a/b/site.css", "css"},
+		{"/assets/site.css/app.js", "404"},
+		{"/assets/a/app.js", "404"},
+		{"/ids/1", "ids"},
+		{"/ids/1/2", "404"},
+	}
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint(`/assets/{path...:.*\.css}`).Handler(testHandler("css"))
+	router.Endpoint("/ids/{id...:int}").Handler(testHandler("ids"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+	}
+}
+
+func TestOrderedVars(t *testing.T) {
+	type testCase struct {
+		url  string
+		vars []Param
+	}
+	cases := []testCase{
+		{"/orgs/acme/repos/trout/issues/12", []Param{{"org", "acme"}, {"repo", "trout"}, {"issueID", "12"}}},
+		{"/posts/1/comments/2", []Param{{"id", "1"}, {"id", "2"}}},
+		{"/files/a/b/c", []Param{{"rest", "a/b/c"}}},
+		{"/static", nil},
+		{"/missing", nil},
+	}
+	var router Router
+	router.Endpoint("/orgs/{org}/repos/{repo}/issues/{issueID}").Handler(testHandler("issues"))
+	router.Endpoint("/posts/{id}/comments/{id}").Handler(testHandler("comments"))
+	router.Endpoint("/files/{rest...}").Handler(testHandler("files"))
+	router.Endpoint("/static").Handler(testHandler("static"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		_, r = router.getHandler(r)
+		if vars := OrderedVars(r); !reflect.DeepEqual(vars, c.vars) {
+			t.Errorf("Expected vars for %s to be %+v, got %+v", c.url, c.vars, vars)
+		}
+	}
+}
+
+func TestConstrainedRouting(t *testing.T) {
+	type testCase struct {
+		url, handler, pattern string
+	}
+	cases := []testCase{
+		{"/users/123", "get-id", "/users/{id:int}"},
+		{"/users/-5", "get-id", "/users/{id:int}"},
+		{"/users/paddy", "get-name", "/users/{name}"},
+		{"/users/me", "get-me", "/users/me"},
+		{"/versions/v2", "get-version", "/versions/{version:v[0-9]+}"},
+		{"/versions/latest", "404", ""},
+		{"/things/0b6e4a5c-8a2c-4f5e-9d3b-1a2b3c4d5e6f", "get-uuid", "/things/{id:uuid}"},
+		{"/things/my-thing", "get-slug", "/things/{slug:slug}"},
+		{"/things/My_Thing", "404", ""},
+	}
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/users/{name}").Methods("GET").Handler(testHandler("get-name"))
+	router.Endpoint("/users/{id:int}").Methods("GET").Handler(testHandler("get-id"))
+	router.Endpoint("/users/me").Methods("GET").Handler(testHandler("get-me"))
+	router.Endpoint("/versions/{version:v[0-9]+}").Methods("GET").Handler(testHandler("get-version"))
+	router.Endpoint("/things/{id:uuid}").Methods("GET").Handler(testHandler("get-uuid"))
+	router.Endpoint("/things/{slug:slug}").Methods("GET").Handler(testHandler("get-slug"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, r := router.getHandler(r)
+		res := string(h.(testHandler))
+		if res != c.handler {
+			t.Errorf("Expected to route %q to %s, routed to %s", c.url, c.handler, res)
+		}
+		if r.Header.Get("Trout-Pattern") != c.pattern {
+			t.Errorf("Expected %q to have a pattern of %q, got %q", c.url, c.pattern, r.Header.Get("Trout-Pattern"))
+		}
+	}
+}
+
+func TestInvalidConstraint(t *testing.T) {
+	var router Router
+	_, err := router.AddEndpoint("/users/{id:[0-9}")
+	if err == nil {
+		t.Error("Expected an error adding an endpoint with an invalid constraint, got nil")
+	}
+	_, err = router.AddPrefix("/users/{id:(}")
+	if err == nil {
+		t.Error("Expected an error adding a prefix with an invalid constraint, got nil")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Endpoint to panic with an invalid constraint")
+		}
+	}()
+	router.Endpoint("/users/{id:[0-9}")
+}
+
+func TestInvalidTemplates(t *testing.T) {
+	invalid := []string{
+		"/posts/{id",
+		"/posts/id}",
+		"/posts/{id}}",
+		"/posts/{{id}",
+		"/posts/{}",
+		"/posts/{:int}",
+		"/posts/{...}",
+		"/files/{rest...}/meta",
+		"/files/{a}-{b}",
+		"/orders/{status:open||closed}",
+		"/orders/{status:|open}",
+		"/orders/{status:open|}",
+		"/files/img-{rest...}",
+		"/files/{name}}.png",
+	}
+	for _, template := range invalid {
+		var router Router
+		if _, err := router.AddEndpoint(template); err == nil {
+			t.Errorf("Expected an error adding endpoint %q, got nil", template)
+		}
+		if _, err := router.AddPrefix(template); err == nil {
+			t.Errorf("Expected an error adding prefix %q, got nil", template)
+		}
+	}
+	valid := []string{
+		"/posts/{id}",
+		"/posts/{id:[0-9]{3}}",
+		"/files/{rest...}",
+		"/posts/{id}/comments/{id}",
+	}
+	for _, template := range valid {
+		var router Router
+		if _, err := router.AddEndpoint(template); err != nil {
+			t.Errorf("Unexpected error adding endpoint %q: %+v", template, err)
+		}
+	}
+}
+
+func TestCaseInsensitiveRouting(t *testing.T) {
+	type testCase struct {
+		url, handler, slug string
+	}
+	cases := []testCase{
+		{"/posts/FooBar", "get-post", "FooBar"},
+		{"/POSTS/FooBar", "get-post", "FooBar"},
+		{"/Posts/foobar", "get-post", "foobar"},
+		{"/STATIC/css/site.css", "static", ""},
+		{"/static", "static", ""},
+	}
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/Posts/{slug}").Methods("GET").Handler(testHandler("get-post"))
+	router.Prefix("/Static").Methods("GET").Handler(testHandler("static"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, r := router.getHandler(r)
+		res := string(h.(testHandler))
+		if res != c.handler {
+			t.Errorf("Expected to route %q to %s, routed to %s", c.url, c.handler, res)
+		}
+		if slug := RequestVars(r).Get("slug"); slug != c.slug {
+			t.Errorf("Expected slug for %q to be %q, got %q", c.url, c.slug, slug)
+		}
+	}
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	type testCase struct {
+		method, url string
+		code        int
+		location    string
+	}
+	cases := []testCase{
+		{"GET", "/posts/foo", http.StatusOK, ""},
+		{"GET", "/posts/foo/", http.StatusMovedPermanently, "/posts/foo"},
+		{"POST", "/posts/foo/?draft=true", http.StatusPermanentRedirect, "/posts/foo?draft=true"},
+		{"GET", "/dirs/foo", http.StatusMovedPermanently, "/dirs/foo/"},
+		{"HEAD", "/dirs/foo?sort=asc", http.StatusMovedPermanently, "/dirs/foo/?sort=asc"},
+		{"GET", "/dirs/foo/", http.StatusOK, ""},
+		{"GET", "/static/foo/", http.StatusOK, ""},
+		{"GET", "/static/", http.StatusOK, ""},
+		{"GET", "/", http.StatusOK, ""},
+	}
+	var router Router
+	router.RedirectTrailingSlash = true
+	router.Endpoint("/posts/{id}").Handler(testHandler("posts"))
+	router.Endpoint("/dirs/{id}/").Handler(testHandler("dirs"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Endpoint("/").Handler(testHandler("root"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected \"%s %s\" to return %d, got %d", c.method, c.url, c.code, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != c.location {
+			t.Errorf("Expected \"%s %s\" to redirect to %q, got %q", c.method, c.url, c.location, loc)
+		}
+	}
+}
+
+func TestRedirectTrailingSlashCleanPath(t *testing.T) {
+	type testCase struct {
+		url, location string
+	}
+	cases := []testCase{
+		{"/posts//foo/", "/api/posts/foo"},
+		{"/posts/./foo/", "/api/posts/foo"},
+		{"/dirs/bar/../foo", "/api/dirs/foo/"},
+	}
+	router := NewRouter(WithCleanPath(), WithRedirectTrailingSlash())
+	router.SetPrefix("/api")
+	router.Endpoint("/posts/{id}").Handler(testHandler("posts"))
+	router.Endpoint("/dirs/{id}/").Handler(testHandler("dirs"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", "/api"+c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("Expected /api%s to return %d, got %d", c.url, http.StatusMovedPermanently, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != c.location {
+			t.Errorf("Expected /api%s to redirect to %q, got %q", c.url, c.location, loc)
+		}
+	}
+}
+
+func TestRedirectTrailingSlashStaysOnHost(t *testing.T) {
+	type testCase struct {
+		uri, location string
+	}
+	cases := []testCase{
+		{"//evil.com/", "/evil.com"},
+		{"///evil.com/?a=b", "/evil.com?a=b"},
+		{"/\\evil.com/", "/%5Cevil.com"},
+		{"/%5Cevil.com/", "/%5Cevil.com"},
+	}
+	var router Router
+	router.RedirectTrailingSlash = true
+	router.Endpoint("/{slug}").Handler(testHandler("slug"))
+	for _, c := range cases {
+		// parse the URI the way the server parses the request line,
+		// as http.NewRequest would treat //evil.com as a host
+		u, err := url.ParseRequestURI(c.uri)
+		if err != nil {
+			t.Fatalf("Error parsing %s: %+v", c.uri, err)
+		}
+		r := &http.Request{Method: "GET", URL: u, Header: http.Header{}, RequestURI: c.uri}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("Expected %s to return %d, got %d", c.uri, http.StatusMovedPermanently, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != c.location {
+			t.Errorf("Expected %s to redirect to %q, got %q", c.uri, c.location, loc)
+		}
+	}
+}
+
+func TestExtensionMethods(t *testing.T) {
+	type testCase struct {
+		method string
+		code   int
+		allow  string
+		body   string
+	}
+	cases := []testCase{
+		{"PROPFIND", http.StatusOK, "", "propfind"},
+		{"MKCOL", http.StatusOK, "", "mkcol"},
+		{"GET", http.StatusOK, "", "get"},
+		{"OPTIONS", http.StatusNoContent, "GET, MKCOL, OPTIONS, PROPFIND", ""},
+		{"POST", http.StatusMethodNotAllowed, "GET, MKCOL, PROPFIND", "405 Method Not Allowed"},
+	}
+	router := NewRouter(WithHandleOPTIONS())
+	router.Endpoint("/files/{path...}").Methods("propfind", "").Handler(testHandler("propfind"))
+	router.Endpoint("/files/{path...}").Handlers(map[string]http.Handler{
+		" MKCOL ": testHandler("mkcol"),
+		"":        testHandler("empty"),
+	})
+	router.Endpoint("/files/{path...}").GET(testHandler("get"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, "/files/docs/a.txt", nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.method, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s to return %d, got %d", c.method, c.code, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("Expected %s to have an Allow header of %q, got %q", c.method, c.allow, allow)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected %s to have a body of %q, got %q", c.method, c.body, body)
+		}
+	}
+	if methods := router.AllowedMethods("/files/docs/a.txt"); !reflect.DeepEqual(methods, []string{"GET", "MKCOL", "PROPFIND"}) {
+		t.Errorf("Expected AllowedMethods to include extension methods, got %v", methods)
+	}
+}
+
+func TestMethodBeatsScoreInDeepPaths(t *testing.T) {
+	// with 60 path elements, a float penalty for unsupported methods
+	// would need more precision than a float64 has, so check that the
+	// method is still what matters most
+	const depth = 60
+	static := strings.Repeat("/a", depth)
+	dynamic := strings.Repeat("/{p}", depth-1) + "/a"
+	var router Router
+	router.Endpoint(static).Methods("GET").Handler(testHandler("static"))
+	router.Endpoint(dynamic).Methods("POST").Handler(testHandler("dynamic"))
+	for method, handler := range map[string]string{"GET": "static", "POST": "dynamic"} {
+		r, err := http.NewRequest(method, static, nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %+v", err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != handler {
+			t.Errorf("Expected %s to route to %s, routed to %s", method, handler, res)
+		}
+	}
+}
+
+func TestMaxPathSegments(t *testing.T) {
+	type testCase struct {
+		max         int
+		prefix, url string
+		code        int
+	}
+	cases := []testCase{
+		{0, "", "/files" + strings.Repeat("/a", 99), http.StatusOK},
+		{0, "", "/files" + strings.Repeat("/a", 100), http.StatusRequestURITooLong},
+		{3, "", "/files/a/b", http.StatusOK},
+		{3, "", "/files/a/b/c", http.StatusRequestURITooLong},
+		{3, "", "/files/a/b/", http.StatusOK},
+		{3, "", "/files/a%2Fb%2Fc/d", http.StatusOK},
+		{3, "", "/files/a%2Fb/c/d", http.StatusRequestURITooLong},
+		{3, "/api", "/api/files/a/b", http.StatusOK},
+		{3, "/api", "/api/files/a/b/c", http.StatusRequestURITooLong},
+		{-1, "", "/files" + strings.Repeat("/a", 1000), http.StatusOK},
+	}
+	for _, c := range cases {
+		router := NewRouter(WithMaxPathSegments(c.max))
+		router.SetPrefix(c.prefix)
+		router.Prefix("/files").Handler(testHandler("files"))
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s to return %d with a limit of %d, got %d", c.url, c.code, c.max, w.Code)
+		}
+		if m := router.Match("GET", c.url); (m.Kind == MatchNotFound) != (c.code != http.StatusOK) {
+			t.Errorf("Expected Match for %s with a limit of %d to be consistent with routing, got %v", c.url, c.max, m.Kind)
+		}
+	}
+}
+
+func TestCatchAllMethods(t *testing.T) {
+	type testCase struct {
+		method, url string
+		code        int
+		allow       string
+		body        string
+	}
+	cases := []testCase{
+		{"OPTIONS", "/default", http.StatusNoContent, "GET, HEAD, OPTIONS", ""},
+		{"OPTIONS", "/posts/foo", http.StatusNoContent, "DELETE, GET, HEAD, OPTIONS", ""},
+		{"OPTIONS", "/except", http.StatusNoContent, "GET, OPTIONS", ""},
+		{"POST", "/default", http.StatusOK, "", "default"},
+		{"OPTIONS", "*", http.StatusNoContent, "DELETE, GET, HEAD, OPTIONS", ""},
+	}
+	router := NewRouter(WithHandleOPTIONS(), WithCatchAllMethods("GET", "HEAD", "OPTIONS"))
+	router.Endpoint("/posts/{id}").Methods("GET", "DELETE").Handler(testHandler("post"))
+	router.Endpoint("/posts/{id}").Handler(testHandler("post-default"))
+	router.Endpoint("/default").Handler(testHandler("default"))
+	router.Endpoint("/except").MethodsExcept("HEAD", "OPTIONS").Handler(testHandler("except"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s %s to return %d, got %d", c.method, c.url, c.code, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("Expected %s %s to have an Allow header of %q, got %q", c.method, c.url, c.allow, allow)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected %s %s to have a body of %q, got %q", c.method, c.url, c.body, body)
+		}
+	}
+	if methods := router.AllowedMethods("/default"); !reflect.DeepEqual(methods, []string{"GET", "HEAD", "OPTIONS"}) {
+		t.Errorf("Expected AllowedMethods to report the catch-all methods, got %v", methods)
+	}
+}
+
+func TestHandleOPTIONS(t *testing.T) {
+	type testCase struct {
+		url   string
+		code  int
+		allow string
+		body  string
+	}
+	cases := []testCase{
+		{"/posts", http.StatusNoContent, "GET, OPTIONS, POST", ""},
+		{"/posts/foo", http.StatusNoContent, "DELETE, GET, OPTIONS", ""},
+		{"/custom", http.StatusOK, "", "custom-options"},
+		{"/missing", http.StatusNotFound, "", "404 Page Not Found"},
+		{"/default", http.StatusOK, "", "default"},
+		{"*", http.StatusNoContent, "DELETE, GET, OPTIONS, POST", ""},
+	}
+	var router Router
+	router.HandleOPTIONS = true
+	router.Endpoint("/posts").Methods("GET", "POST").Handler(testHandler("posts"))
+	router.Endpoint("/posts").Methods("GET").Handler(testHandler("posts"))
+	router.Endpoint("/posts/{id}").Methods("GET", "DELETE").Handler(testHandler("post"))
+	router.Endpoint("/posts/{id}").Handler(testHandler("post-default"))
+	router.Endpoint("/custom").Methods("OPTIONS").Handler(testHandler("custom-options"))
+	router.Endpoint("/default").Handler(testHandler("default"))
+	for _, c := range cases {
+		r, err := http.NewRequest("OPTIONS", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected OPTIONS %s to return %d, got %d", c.url, c.code, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("Expected OPTIONS %s to have an Allow header of %q, got %q", c.url, c.allow, allow)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected OPTIONS %s to have a body of %q, got %q", c.url, c.body, body)
+		}
+	}
+}
+
+func TestHandleHEAD(t *testing.T) {
+	type testCase struct {
+		url           string
+		code          int
+		body          string
+		contentLength string
+		middleware    string
+	}
+	cases := []testCase{
+		{"/posts", http.StatusOK, "", "5", "get"},
+		{"/custom", http.StatusOK, "head", "", ""},
+		{"/created", http.StatusCreated, "", "3", ""},
+		{"/length", http.StatusOK, "", "100", ""},
+		{"/post-only", http.StatusMethodNotAllowed, "405 Method Not Allowed", "", ""},
+	}
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Middleware", name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	var router Router
+	router.HandleHEAD = true
+	router.Endpoint("/posts").Methods("GET").Middleware(mw("get")).Handler(testHandler("posts"))
+	router.Endpoint("/custom").Methods("GET").Handler(testHandler("get"))
+	router.Endpoint("/custom").Methods("HEAD").Handler(testHandler("head"))
+	router.Endpoint("/created").Methods("GET").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("new")) //nolint:errcheck
+	}))
+	router.Endpoint("/length").Methods("GET").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("short")) //nolint:errcheck
+	}))
+	router.Endpoint("/post-only").Methods("POST").Handler(testHandler("post"))
+	for _, c := range cases {
+		r, err := http.NewRequest("HEAD", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected HEAD %s to return %d, got %d", c.url, c.code, w.Code)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected HEAD %s to have a body of %q, got %q", c.url, c.body, body)
+		}
+		if cl := w.Header().Get("Content-Length"); cl != c.contentLength {
+			t.Errorf("Expected HEAD %s to have a Content-Length of %q, got %q", c.url, c.contentLength, cl)
+		}
+		if m := w.Header().Get("Middleware"); m != c.middleware {
+			t.Errorf("Expected HEAD %s to run middleware %q, got %q", c.url, c.middleware, m)
+		}
+	}
+}
+
+func TestParamHeaders(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		var router Router
+		router.LegacyHeaderVars = legacy
+		router.Endpoint("/posts/{slug}").Handler(testHandler("post"))
+		r, err := http.NewRequest("GET", "/posts/foo", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %+v", err)
+		}
+		r.Header.Set("Trout-Param-Admin", "true")
+		r.Header.Set("Trout-Pattern", "/spoofed")
+		_, r = router.getHandler(r)
+		vars := RequestVars(r)
+		if vars.Get("admin") != "" {
+			t.Errorf("Expected client-supplied param to be ignored, got %q", vars.Get("admin"))
+		}
+		if r.Header.Get("Trout-Param-Admin") != "" {
+			t.Errorf("Expected client-supplied param header to be removed, got %q", r.Header.Get("Trout-Param-Admin"))
+		}
+		if r.Header.Get("Trout-Pattern") != "/posts/{slug}" {
+			t.Errorf("Expected pattern header to be %q, got %q", "/posts/{slug}", r.Header.Get("Trout-Pattern"))
+		}
+		if vars.Get("slug") != "foo" {
+			t.Errorf("Expected slug to be %q, got %q", "foo", vars.Get("slug"))
+		}
+		header := r.Header.Get("Trout-Param-Slug")
+		if legacy && header != "foo" {
+			t.Errorf("Expected slug header to be %q with LegacyHeaderVars, got %q", "foo", header)
+		}
+		if !legacy && header != "" {
+			t.Errorf("Expected no slug header without LegacyHeaderVars, got %q", header)
+		}
+	}
+}
+
+func TestDisableHeaders(t *testing.T) {
+	router := Router{DisableHeaders: true, LegacyHeaderVars: true, Timing: true}
+	router.Endpoint("/posts/{slug}").Handler(testHandler("post"))
+	r, err := http.NewRequest("GET", "/posts/foo", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	r.Header.Set("Trout-Pattern", "/spoofed")
+	_, r = router.getHandler(r)
+	for h := range r.Header {
+		if strings.HasPrefix(h, "Trout-") {
+			t.Errorf("Expected no Trout- headers, got %s: %v", h, r.Header[h])
+		}
+	}
+	if slug := RequestVars(r).Get("slug"); slug != "foo" {
+		t.Errorf("Expected slug to be %q, got %q", "foo", slug)
+	}
+	if pattern := Pattern(r); pattern != "/posts/{slug}" {
+		t.Errorf("Expected pattern to be %q, got %q", "/posts/{slug}", pattern)
+	}
+	if methods := methodsFromRequest(r); methods != nil {
+		t.Errorf("Expected no methods, got %v", methods)
+	}
+}
+
+func TestDisableMethodNotAllowed(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "get"},
+		{"POST", "/posts", "404"},
+		{"GET", "/missing", "404"},
+	}
+	router := Router{DisableMethodNotAllowed: true}
+	router.Handle404 = testHandler("404")
+	router.Handle405 = testHandler("405")
+	router.Endpoint("/posts").GET(testHandler("get"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestOnMatch(t *testing.T) {
+	type testCase struct {
+		method, url, pattern string
+		matched              bool
+		status               int
+	}
+	cases := []testCase{
+		{"GET", "/posts/foo", "/posts/{slug}", true, http.StatusOK},
+		{"POST", "/posts/foo", "/posts/{slug}", true, http.StatusMethodNotAllowed},
+		{"GET", "/posts/foo/", "/posts/{slug}", true, http.StatusMovedPermanently},
+		{"OPTIONS", "/posts/foo", "/posts/{slug}", true, http.StatusNoContent},
+		{"GET", "/missing", "", false, http.StatusNotFound},
+	}
+	var got testCase
+	router := Router{RedirectTrailingSlash: true, HandleOPTIONS: true}
+	router.OnMatch = func(r *http.Request, pattern string, matched bool, status int) {
+		got = testCase{r.Method, r.URL.Path, pattern, matched, status}
+	}
+	router.Endpoint("/posts/{slug}").GET(testHandler("post"))
+	for _, c := range cases {
+		got = testCase{}
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		router.getHandler(r)
+		if got != c {
+			t.Errorf("Expected OnMatch to be called with %+v, got %+v", c, got)
+		}
+	}
+}
+
+func TestPrefixAndDeeperEndpoint(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/a", "a"},
+		{"GET", "/a/", "a"},
+		{"GET", "/a/b", "ab"},
+		{"POST", "/a/b", "prefix-post"},
+		{"GET", "/a/c", "prefix"},
+		{"GET", "/a/b/c", "prefix"},
+	}
+	var router Router
+	router.Prefix("/a").Handler(testHandler("prefix"))
+	router.Prefix("/a").POST(testHandler("prefix-post"))
+	router.Endpoint("/a/b").GET(testHandler("ab"))
+	router.Endpoint("/a").GET(testHandler("a"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+	if conflicts := router.Validate(); conflicts != nil {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestLongestPrefix(t *testing.T) {
+	type testCase struct {
+		url, handler, remainder string
+	}
+	cases := []testCase{
+		{"/static/img/logo.png", "img", "logo.png"},
+		{"/static/img", "img", ""},
+		{"/static/imgs/logo.png", "static", "imgs/logo.png"},
+		{"/static/css/site.css", "static", "css/site.css"},
+		{"/static/img/icons/x.svg", "icons", "x.svg"},
+		{"/static/v2/img/logo.png", "versioned", "logo.png"},
+	}
+	var router Router
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Prefix("/static/img/icons").Handler(testHandler("icons"))
+	router.Prefix("/static/img").Handler(testHandler("img"))
+	router.Prefix("/static/{version}/img").Handler(testHandler("versioned"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, r := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+		if remainder := PrefixRemainder(r); remainder != c.remainder {
+			t.Errorf("Expected %s to have remainder %q, got %q", c.url, c.remainder, remainder)
+		}
+	}
+}
+
+func TestRepeatedParamsAcrossPrefix(t *testing.T) {
+	type testCase struct {
+		url string
+		ids []string
+	}
+	cases := []testCase{
+		{"/1/x/2", []string{"1", "2"}},
+		{"/1/x/2/3/4", []string{"1", "2"}},
+		{"/1/x/2/y/3", []string{"1", "2", "3"}},
+		{"/1/x/2/y/3/4", []string{"1", "2", "3"}},
+		{"http://0.example.com/1/x/2/3", []string{"0", "1", "2"}},
+	}
+	var router Router
+	router.Prefix("/{id}/x/{id}").Handler(testHandler("prefix"))
+	router.Prefix("/{id}/x/{id}/y/{id}").Handler(testHandler("deeper"))
+	router.Host("{id}.example.com").Prefix("/{id}/x/{id}").Handler(testHandler("host"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		_, r = router.getHandler(r)
+		if ids := RequestVars(r)["Id"]; !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("Expected ids for %s to be %v, got %v", c.url, c.ids, ids)
+		}
+		var ordered []string
+		for _, param := range OrderedVars(r) {
+			ordered = append(ordered, param.Value)
+		}
+		if !reflect.DeepEqual(ordered, c.ids) {
+			t.Errorf("Expected ordered ids for %s to be %v, got %v", c.url, c.ids, ordered)
+		}
 	}
-	for in, expect := range cases {
-		t.Logf("Testing case %s", in)
-		result := keysFromString(in)
-		if len(result) != len(expect) {
-			t.Errorf("Expected %d results, got %d: %+v", len(expect), len(result), result)
-			continue
+}
+
+func TestEmptyPath(t *testing.T) {
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/").Handler(testHandler("root"))
+	router.Endpoint("/{slug}").Handler(testHandler("slug"))
+	for _, path := range []string{"", "/", "//"} {
+		r := &http.Request{Method: "GET", URL: &url.URL{Path: path}, Header: http.Header{}}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != "root" {
+			t.Errorf("Expected to route %q to root, routed to %s", path, res)
 		}
-		for pos, res := range result {
-			if !res.equals(expect[pos]) {
-				t.Errorf("Expected result %d to be %+v, was %+v", pos, expect[pos], res)
+	}
+	if nodes := findNodes(router.trie.root, nil, nil, nil); nodes != nil {
+		t.Errorf("Expected no nodes for an empty path, got %v", nodes)
+	}
+}
+
+func TestEscapedPaths(t *testing.T) {
+	type testCase struct {
+		url, handler, key string
+	}
+	cases := []testCase{
+		{"/keys/a%2Fb", "get-key", "a/b"},
+		{"/keys/a%20b", "get-key", "a b"},
+		{"/keys/plain", "get-key", "plain"},
+		{"/keys/a/b", "404", ""},
+		{"/caf%C3%A9/x", "get-cafe", ""},
+	}
+	var router Router
+	router.Handle404 = testHandler("404")
+	router.Endpoint("/keys/{key}").Handler(testHandler("get-key"))
+	router.Endpoint("/café/x").Handler(testHandler("get-cafe"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, r := router.getHandler(r)
+		res := string(h.(testHandler))
+		if res != c.handler {
+			t.Errorf("Expected to route %q to %s, routed to %s", c.url, c.handler, res)
+		}
+		if key := RequestVars(r).Get("key"); key != c.key {
+			t.Errorf("Expected key for %q to be %q, got %q", c.url, c.key, key)
+		}
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	type testCase struct {
+		url, clean, strict string
+	}
+	cases := []testCase{
+		{"/posts/foo", "foo", "foo"},
+		{"/posts//foo", "foo", "404"},
+		{"/posts/./foo", "foo", "404"},
+		{"/posts/bar/../foo", "foo", "404"},
+		{"//posts/foo/", "foo", "foo"},
+		{"/posts/./", "404", "."},
+	}
+	for _, clean := range []bool{true, false} {
+		var router Router
+		router.CleanPath = clean
+		router.Handle404 = testHandler("404")
+		router.Endpoint("/posts/{slug}").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(RequestVars(r).Get("slug"))) //nolint:errcheck
+		}))
+		for _, c := range cases {
+			r, err := http.NewRequest("GET", "http://example.com"+c.url, nil)
+			if err != nil {
+				t.Fatalf("Error creating request for %s: %+v", c.url, err)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			expected := c.strict
+			if clean {
+				expected = c.clean
+			}
+			if w.Body.String() != expected {
+				t.Errorf("Expected %q with CleanPath %v to return %q, got %q", c.url, clean, expected, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestSortedMethods(t *testing.T) {
+	var router Router
+	router.Endpoint("/posts").Methods("PUT", "GET", "DELETE", "POST", "PATCH").Handler(testHandler("posts"))
+	router.Endpoint("/posts").Methods("CONNECT").Handler(testHandler("connect"))
+	router.Endpoint("/default").Methods("POST", "GET").Handler(testHandler("default"))
+	router.Endpoint("/default").Handler(testHandler("default"))
+	type testCase struct {
+		url, methods string
+	}
+	cases := []testCase{
+		{"/posts", "CONNECT, DELETE, GET, PATCH, POST, PUT"},
+		{"/default", "GET, POST"},
+	}
+	for _, c := range cases {
+		// run a few times, so map iteration order would show up
+		for i := 0; i < 10; i++ {
+			r, err := http.NewRequest("OPTIONS", c.url, nil)
+			if err != nil {
+				t.Fatalf("Error creating request for %s: %+v", c.url, err)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if methods := strings.Join(r.Header["Trout-Methods"], ", "); methods != c.methods {
+				t.Errorf("Expected %s to have methods %q, got %q", c.url, c.methods, methods)
+			}
+			if c.url == "/posts" {
+				if allow := w.Header().Get("Allow"); allow != c.methods {
+					t.Errorf("Expected %s to have an Allow header of %q, got %q", c.url, c.methods, allow)
+				}
+			}
+		}
+	}
+}
+
+func TestDefaultErrorHandlers(t *testing.T) {
+	var router Router
+	router.Endpoint("/posts").Methods("PUT", "GET").Handler(testHandler("posts"))
+	for _, url := range []string{"/posts", "/missing"} {
+		r, err := http.NewRequest("DELETE", url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+			t.Errorf("Expected %s to have a Content-Type of text/plain, got %q", url, ct)
+		}
+	}
+
+	// requests that weren't routed by a Router fall back on the
+	// Trout-Methods header, which may not be sorted
+	r, err := http.NewRequest("DELETE", "/posts", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	r.Header["Trout-Methods"] = []string{"PUT", "GET", "PUT"}
+	w := httptest.NewRecorder()
+	default405Handler.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); allow != "GET, PUT" {
+		t.Errorf("Expected an Allow header of %q, got %q", "GET, PUT", allow)
+	}
+}
+
+func TestMethodNormalization(t *testing.T) {
+	var router Router
+	router.Handle405 = testHandler("405")
+	router.Endpoint("/posts").Methods("get", "Post").Handler(testHandler("posts"))
+	router.Prefix("/static").Methods("get").Handler(testHandler("static"))
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "posts"},
+		{"POST", "/posts", "posts"},
+		{"PUT", "/posts", "405"},
+		{"GET", "/static/foo", "static"},
+	}
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/files/foo", "dir-prefix"},
+		{"GET", "/files/foo/bar", "dir-prefix"},
+		{"GET", "/files", "dir-prefix"},
+		{"POST", "/files/foo", "files-post"},
+		{"GET", "/users/me", "users-dynamic"},
+		{"GET", "/users/paddy", "users-dynamic"},
+		{"GET", "/posts/me", "posts-me"},
+	}
+	var router Router
+	router.Prefix("/{dir}").Priority(1).Methods("GET").Handler(testHandler("dir-prefix"))
+	router.Endpoint("/files/{id}").Methods("GET", "POST").Handler(testHandler("files-post"))
+	router.Endpoint("/files").Methods("GET").Handler(testHandler("files-static"))
+	var usersRouter Router
+	usersRouter.Endpoint("/users/me").Priority(-1).Handler(testHandler("users-me"))
+	usersRouter.Endpoint("/users/{id}").Handler(testHandler("users-dynamic"))
+	usersRouter.Endpoint("/posts/me").Handler(testHandler("posts-me"))
+	usersRouter.Endpoint("/posts/{id}").Handler(testHandler("posts-dynamic"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if strings.HasPrefix(c.url, "/users") || strings.HasPrefix(c.url, "/posts") {
+			h, _ = usersRouter.getHandler(r)
+		}
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestHandlerFunc(t *testing.T) {
+	type testCase struct {
+		method, url, body string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "endpoint"},
+		{"POST", "/posts", "methods"},
+		{"GET", "/static/site.css", "prefix"},
+	}
+	write := func(body string) func(http.ResponseWriter, *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	var router Router
+	router.Endpoint("/posts").HandlerFunc(write("endpoint"))
+	router.Endpoint("/posts").Methods("POST").HandlerFunc(write("methods"))
+	router.Prefix("/static").HandlerFunc(write("prefix"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if res := w.Body.String(); res != c.body {
+			t.Errorf("Expected \"%s %s\" to write %q, wrote %q", c.method, c.url, c.body, res)
+		}
+	}
+}
+
+func TestHandlerChaining(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "posts-default"},
+		{"POST", "/posts", "posts-post"},
+		{"GET", "/static/site.css", "static-default"},
+		{"DELETE", "/static/site.css", "static-delete"},
+	}
+	var router Router
+	router.Endpoint("/posts").Handler(testHandler("posts-default")).Methods("POST").Handler(testHandler("posts-post"))
+	router.Prefix("/static").Handler(testHandler("static-default")).Priority(1).DELETE(testHandler("static-delete"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestNotFoundNext(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/new", "new"},
+		{"POST", "/new", "405"},
+		{"GET", "/old", "old"},
+		{"GET", "/missing", "404"},
+	}
+	var legacy Router
+	legacy.Handle404 = testHandler("404")
+	legacy.Endpoint("/old").Handler(testHandler("old"))
+	var router Router
+	router.Handle404 = testHandler("unused-404")
+	router.Handle405 = testHandler("405")
+	router.Endpoint("/new").Methods("GET").Handler(testHandler("new"))
+	router.NotFoundNext(legacy)
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, r := router.getHandler(r)
+		if next, ok := h.(Router); ok {
+			h, _ = next.getHandler(r)
+		}
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestPreMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name+" pattern="+Pattern(r))
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	var router Router
+	router.SetPreMiddleware(record("pre1"), record("pre2"))
+	router.SetMiddleware(record("post"))
+	router.Endpoint("/posts/{id}").Middleware(record("route")).Handler(testHandler("posts"))
+
+	for _, path := range []string{"/posts/1", "/missing"} {
+		calls = nil
+		r, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", path, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		expected := []string{"pre1 pattern=", "pre2 pattern=", "post pattern=/posts/{id}", "route pattern=/posts/{id}"}
+		if path == "/missing" {
+			expected = []string{"pre1 pattern=", "pre2 pattern=", "post pattern="}
+		}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("Expected middleware calls for %s to be %v, got %v", path, expected, calls)
+		}
+	}
+}
+
+func TestRouteMiddlewareSeesRouting(t *testing.T) {
+	type testCase struct {
+		method, url string
+		code        int
+		body        string
+	}
+	cases := []testCase{
+		{"GET", "/users/alice/posts/1", http.StatusOK, "post"},
+		{"GET", "/users/bob/posts/1", http.StatusForbidden, "/users/{owner}/posts/{id} bob 1"},
+		{"DELETE", "/users/bob/posts/1", http.StatusForbidden, "/users/{owner}/posts/{id} bob 1"},
+		{"GET", "/files/bob/a.txt", http.StatusForbidden, "/files/{owner::prefix} bob a.txt"},
+	}
+	reject := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			vars := RequestVars(r)
+			if vars.Get("owner") == "alice" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusForbidden)
+			rest := vars.Get("id")
+			if IsPrefixMatch(r) {
+				rest = PrefixRemainder(r)
 			}
+			w.Write([]byte(Pattern(r) + " " + vars.Get("owner") + " " + rest))
+		})
+	}
+	var router Router
+	router.Endpoint("/users/{owner}/posts/{id}").Methods("GET").Middleware(reject).Handler(testHandler("post"))
+	router.Endpoint("/users/{owner}/posts/{id}").Middleware(reject).Handler(testHandler("post"))
+	router.Prefix("/files/{owner}").Middleware(reject).Handler(testHandler("files"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s %s to return %d, got %d", c.method, c.url, c.code, w.Code)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected %s %s to have a body of %q, got %q", c.method, c.url, c.body, body)
+		}
+	}
+}
+
+func TestMethodMiddlewareComposes(t *testing.T) {
+	var calls []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	type testCase struct {
+		method, url string
+		calls       []string
+	}
+	cases := []testCase{
+		{"GET", "/posts", []string{"group", "endpoint", "get"}},
+		{"PUT", "/posts", []string{"group", "endpoint"}},
+		{"POST", "/posts", []string{"group", "endpoint", "post"}},
+		{"GET", "/files/a.txt", []string{"prefix", "get"}},
+		{"GET", "/users", []string{"get"}},
+	}
+	var router Router
+	posts := router.Group("/", record("group")).Endpoint("/posts")
+	posts.Middleware(record("endpoint")).Handler(testHandler("posts"))
+	posts.Methods("GET").Middleware(record("get")).Handler(testHandler("posts-get"))
+	posts.Methods("POST").Middleware(record("post")).Handler(testHandler("posts-post"))
+	router.Prefix("/files").Middleware(record("prefix")).Methods("GET").Middleware(record("get")).Handler(testHandler("files"))
+	router.Endpoint("/users").Methods("GET").Middleware(record("get")).Handler(testHandler("users"))
+	for _, c := range cases {
+		calls = nil
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(calls, c.calls) {
+			t.Errorf("Expected middleware calls for \"%s %s\" to be %v, got %v", c.method, c.url, c.calls, calls)
 		}
 	}
 }
 
+func TestAddAndClearMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	type testCase struct {
+		method, url string
+		calls       []string
+	}
+	cases := []testCase{
+		{"GET", "/posts", []string{"router-a", "router-b", "endpoint-a", "endpoint-b"}},
+		{"POST", "/posts", []string{"router-a", "router-b", "endpoint-a", "endpoint-b", "post-b"}},
+		{"GET", "/static/site.css", []string{"router-a", "router-b"}},
+	}
+	var router Router
+	router.SetMiddleware(record("router-a"))
+	router.AddMiddleware(record("router-b"))
+	router.Endpoint("/posts").
+		Middleware(record("endpoint-a")).
+		AddMiddleware(record("endpoint-b")).
+		Handler(testHandler("posts")).
+		Methods("POST").
+		Middleware(record("post-a")).
+		ClearMiddleware().
+		AddMiddleware(record("post-b")).
+		Handler(testHandler("posts-post"))
+	router.Prefix("/static").AddMiddleware(record("prefix")).ClearMiddleware().Handler(testHandler("static"))
+	for _, c := range cases {
+		calls = nil
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(calls, c.calls) {
+			t.Errorf("Expected middleware calls for \"%s %s\" to be %v, got %v", c.method, c.url, c.calls, calls)
+		}
+	}
+
+	router.ClearMiddleware()
+	calls = nil
+	r, err := http.NewRequest("GET", "/static/site.css", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	if len(calls) != 0 {
+		t.Errorf("Expected no middleware calls after clearing, got %v", calls)
+	}
+}
+
+func TestDeepPathScoring(t *testing.T) {
+	type testCase struct {
+		url, handler string
+	}
+	static := strings.Repeat("/a", 20)
+	lastDynamic := strings.Repeat("/a", 19) + "/{last}"
+	nextToLastDynamic := strings.Repeat("/a", 18) + "/{next}/a"
+	cases := []testCase{
+		{static, "static"},
+		{strings.Repeat("/a", 19) + "/b", "last-dynamic"},
+		{strings.Repeat("/a", 18) + "/b/a", "next-to-last-dynamic"},
+	}
+	var router Router
+	router.Endpoint(nextToLastDynamic).Handler(testHandler("next-to-last-dynamic"))
+	router.Endpoint(lastDynamic).Handler(testHandler("last-dynamic"))
+	router.Endpoint(static).Handler(testHandler("static"))
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+	}
+
+	// without the static endpoint, the dynamic element closest to the
+	// end of the path should win
+	var dynamicRouter Router
+	dynamicRouter.Endpoint(nextToLastDynamic).Handler(testHandler("next-to-last-dynamic"))
+	dynamicRouter.Endpoint(lastDynamic).Handler(testHandler("last-dynamic"))
+	r, err := http.NewRequest("GET", static, nil)
+	if err != nil {
+		t.Fatalf("Error creating request for %s: %+v", static, err)
+	}
+	h, _ := dynamicRouter.getHandler(r)
+	if res := string(h.(testHandler)); res != "last-dynamic" {
+		t.Errorf("Expected to route %s to last-dynamic, routed to %s", static, res)
+	}
+}
+
 var benchRouter Router
 var benchTests []string
 var benchMethods = [...]string{"GET", "POST", "PUT", "DELETE"}
 
+// benchSeed seeds the routes the benchmarks use, so they're the same every
+// time the benchmarks run and results can be compared.
+const benchSeed = 1
+
+// init defines 100 random routes on benchRouter, with a request for each in
+// benchTests. Each route has 1 to 4 path elements, each of which has a 1 in 4
+// chance of being a parameter instead of a static element. 1 in 10 routes is
+// a Prefix, whose request has an extra path element under it. Each route has
+// a GET handler half the time and a POST handler half the time, independently,
+// and a default handler if it has neither.
 func init() {
+	rng := rand.New(rand.NewSource(benchSeed))
 	for i := 0; i < 100; i++ {
-		rand.Seed(time.Now().UnixNano())
-		depth := rand.Intn(4) + 1
+		depth := rng.Intn(4) + 1
 		var route string
 		var req string
 		for x := 0; x < depth; x++ {
-			rand.Seed(time.Now().UnixNano())
-			param := rand.Intn(1) == 1
-			pieceLength := rand.Intn(24) + 1
+			param := rng.Intn(4) == 0
+			pieceLength := rng.Intn(24) + 1
 			piece := make([]byte, pieceLength)
-			rand.Read(piece)
-			pieceStr := base64.URLEncoding.EncodeToString(piece)
+			rng.Read(piece)
+			pieceStr := base64.RawURLEncoding.EncodeToString(piece)
 			req = req + "/" + pieceStr
 			if param {
-				pieceStr = "{" + pieceStr + "}"
+				pieceStr = "{p" + strconv.Itoa(x) + "}"
 			}
 			route = route + "/" + pieceStr
 		}
-		benchTests = append(benchTests, req)
-		endpoint := benchRouter.Endpoint(route)
-		rand.Seed(time.Now().UnixNano())
-		get := rand.Intn(1) == 1
-		rand.Seed(time.Now().UnixNano())
-		post := rand.Intn(1) == 1
-		catchAll := !get && !post
 		var methods []string
-		if get {
+		if rng.Intn(2) == 0 {
 			methods = append(methods, "GET")
 		}
-		if post {
+		if rng.Intn(2) == 0 {
 			methods = append(methods, "POST")
 		}
-		if catchAll {
+		if len(methods) < 1 {
 			methods = append(methods, catchAllMethod)
 		}
-		endpoint.Methods(methods...).Handler(testHandler("benchmark"))
+		if rng.Intn(10) == 0 {
+			benchTests = append(benchTests, req+"/rest")
+			benchRouter.Prefix(route).Methods(methods...).Handler(testHandler("benchmark"))
+			continue
+		}
+		benchTests = append(benchTests, req)
+		benchRouter.Endpoint(route).Methods(methods...).Handler(testHandler("benchmark"))
 	}
 }
 
 func BenchmarkRouting(b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
 		w := httptest.NewRecorder()
@@ -160,3 +1673,49 @@ func BenchmarkRouting(b *testing.B) {
 		benchRouter.ServeHTTP(w, req)
 	}
 }
+
+func BenchmarkMatch(b *testing.B) {
+	reqs := make([]*http.Request, 0, len(benchTests))
+	for i, route := range benchTests {
+		req, err := http.NewRequest(benchMethods[i%len(benchMethods)], route, nil)
+		if err != nil {
+			b.Fatalf(err.Error())
+		}
+		reqs = append(reqs, req)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchRouter.getHandler(reqs[i%len(reqs)])
+	}
+}
+
+func BenchmarkRouteRequest(b *testing.B) {
+	reqs := make([]*http.Request, 0, len(benchTests))
+	for i, route := range benchTests {
+		req, err := http.NewRequest(benchMethods[i%len(benchMethods)], route, nil)
+		if err != nil {
+			b.Fatalf(err.Error())
+		}
+		reqs = append(reqs, req)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchRouter.routeRequest(reqs[i%len(reqs)])
+	}
+}
+
+func BenchmarkRoutingDynamic(b *testing.B) {
+	var router Router
+	router.Endpoint("/users/{user}/posts/{id}").Handler(testHandler("post"))
+	req, err := http.NewRequest("GET", "/users/alice/posts/1", nil)
+	if err != nil {
+		b.Fatalf(err.Error())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.getHandler(req)
+	}
+}
@@ -0,0 +1,152 @@
+package trout
+
+import (
+	"sort"
+)
+
+// RouteInfo describes an Endpoint or Prefix registered on a Router.
+type RouteInfo struct {
+	// Pattern is the URL template of the Endpoint or Prefix, as it would
+	// be set in the Trout-Pattern header.
+	Pattern string
+	// Methods holds the methods the Endpoint or Prefix has handlers for,
+	// sorted. A default handler set using the Handler method is listed
+	// as "*".
+	Methods []string
+	// Prefix is true if the route is a Prefix, and false if it is an
+	// Endpoint.
+	Prefix bool
+	// Query holds the query parameters the Endpoint requires, if it was
+	// created using Endpoint.Query, the way they'd be written in a query
+	// string.
+	Query string
+}
+
+// Routes returns a description of every Endpoint and Prefix registered on
+// `router`. Routes are returned in a stable order: a depth-first walk of the
+// path elements, with static path elements sorted alphabetically and visited
+// before dynamic path elements, which are visited in the order they were
+// registered. Routes defined through a Host come after the rest, grouped by
+// host template, in the order the templates were first used. Endpoints
+// created using Endpoint.Query come right after the Endpoint they were
+// created from, in the order they were created.
+func (router Router) Routes() []RouteInfo {
+	if router.trie == nil {
+		return nil
+	}
+	router.trie.RLock()
+	defer router.trie.RUnlock()
+
+	var routes []RouteInfo
+	for _, root := range router.trie.roots() {
+		walkTerminators(root, func(n *node) {
+			methods := make([]string, 0, len(n.methods))
+			for method := range n.methods {
+				methods = append(methods, method)
+			}
+			sort.Strings(methods)
+			routes = append(routes, RouteInfo{
+				Pattern: pattern(router.prefix, n),
+				Methods: methods,
+				Prefix:  n.parent != nil && n.parent.value.prefix,
+				Query:   queryString(n),
+			})
+		})
+	}
+	return routes
+}
+
+// walkTerminators calls `fn` for every terminator under `n`, depth-first,
+// followed by the terminators created from it using Endpoint.Query. Static
+// children are visited in alphabetical order before wild children, which
+// are visited in the order they were added.
+func walkTerminators(n *node, fn func(*node)) {
+	if n == nil {
+		return
+	}
+	if n.terminator != nil {
+		fn(n.terminator)
+		for _, variant := range n.terminator.queryVariants {
+			fn(variant)
+		}
+	}
+	for _, static := range n.staticChildren() {
+		walkTerminators(static, fn)
+	}
+	for _, wild := range n.wildChildren {
+		walkTerminators(wild, fn)
+	}
+}
+
+// Walk calls `fn` for every Endpoint and Prefix registered on `router`, in
+// the same order as Routes, with the same pattern, methods, and whether it's
+// a Prefix that Routes would describe it with. Endpoints created using
+// Endpoint.Query are visited with the pattern of the Endpoint they were
+// created from. If `fn` returns an error, Walk stops and returns it.
+//
+// The routes are collected before `fn` is first called, so `fn` can safely
+// use `router`.
+func (router Router) Walk(fn func(pattern string, methods []string, isPrefix bool) error) error {
+	for _, route := range router.Routes() {
+		if err := fn(route.Pattern, route.Methods, route.Prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of Endpoints and Prefixes registered on `router`,
+// the number of routes Routes would return.
+func (router Router) Len() int {
+	endpoints, prefixes := router.count()
+	return endpoints + prefixes
+}
+
+// EndpointCount returns the number of Endpoints registered on `router`.
+func (router Router) EndpointCount() int {
+	endpoints, _ := router.count()
+	return endpoints
+}
+
+// PrefixCount returns the number of Prefixes registered on `router`.
+func (router Router) PrefixCount() int {
+	_, prefixes := router.count()
+	return prefixes
+}
+
+// count returns the number of Endpoints and Prefixes registered on `router`.
+func (router Router) count() (endpoints, prefixes int) {
+	if router.trie == nil {
+		return 0, 0
+	}
+	router.trie.RLock()
+	defer router.trie.RUnlock()
+	for _, root := range router.trie.roots() {
+		walkTerminators(root, func(n *node) {
+			if n.parent != nil && n.parent.value.prefix {
+				prefixes++
+			} else {
+				endpoints++
+			}
+		})
+	}
+	return endpoints, prefixes
+}
+
+// methods returns every method any Endpoint or Prefix registered on `router`
+// has a handler for, sorted and de-duplicated, not including the catch-all
+// method.
+func (router Router) methods() []string {
+	if router.trie == nil {
+		return nil
+	}
+	router.trie.RLock()
+	defer router.trie.RUnlock()
+	var methods []string
+	for _, root := range router.trie.roots() {
+		walkTerminators(root, func(n *node) {
+			methods = append(methods, terminatorMethods(n, router.CatchAllMethods)...)
+		})
+	}
+	return sortMethods(methods)
+}
@@ -0,0 +1,91 @@
+package trout
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	var router Router
+	router.SetPrefix("/api")
+	router.Endpoint("/posts/{slug}").Methods("POST", "GET").Handler(testHandler("post"))
+	router.Endpoint("/posts").Methods("GET").Handler(testHandler("posts"))
+	router.Endpoint("/posts").Query("tag", "").Methods("GET").Handler(testHandler("tagged"))
+	router.Endpoint("/posts").Query("draft", "true").Methods("GET", "DELETE").Handler(testHandler("drafts"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Endpoint("/").Handler(testHandler("root"))
+	router.Endpoint("/about").Methods("GET").Handler(testHandler("about"))
+	router.Endpoint("/{id}").Methods("DELETE").Handler(testHandler("id"))
+
+	expected := []RouteInfo{
+		{Pattern: "/api", Methods: []string{"*"}},
+		{Pattern: "/api/about", Methods: []string{"GET"}},
+		{Pattern: "/api/posts", Methods: []string{"GET"}},
+		{Pattern: "/api/posts", Methods: []string{"GET"}, Query: "tag"},
+		{Pattern: "/api/posts", Methods: []string{"DELETE", "GET"}, Query: "draft=true"},
+		{Pattern: "/api/posts/{slug}", Methods: []string{"GET", "POST"}},
+		{Pattern: "/api/static::prefix", Methods: []string{"*"}, Prefix: true},
+		{Pattern: "/api/{id}", Methods: []string{"DELETE"}},
+	}
+	routes := router.Routes()
+	if !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected routes to be %+v, got %+v", expected, routes)
+	}
+
+	var empty Router
+	if routes := empty.Routes(); routes != nil {
+		t.Errorf("Expected no routes for an empty router, got %+v", routes)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var router Router
+	router.Endpoint("/posts/{slug}").Methods("POST", "GET").Handler(testHandler("post"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Endpoint("/about").Methods("GET").Handler(testHandler("about"))
+
+	var visited []RouteInfo
+	err := router.Walk(func(pattern string, methods []string, isPrefix bool) error {
+		visited = append(visited, RouteInfo{Pattern: pattern, Methods: methods, Prefix: isPrefix})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error walking router: %+v", err)
+	}
+	if !reflect.DeepEqual(visited, router.Routes()) {
+		t.Errorf("Expected Walk to visit %v, visited %v", router.Routes(), visited)
+	}
+
+	stop := errors.New("stop")
+	var count int
+	err = router.Walk(func(pattern string, methods []string, isPrefix bool) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("Expected Walk to stop after the first error, got %v after %d calls", err, count)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	var router Router
+	if router.Len() != 0 || router.EndpointCount() != 0 || router.PrefixCount() != 0 {
+		t.Errorf("Expected an empty Router to have no routes")
+	}
+	router.Endpoint("/posts/{slug}").GET(testHandler("post"))
+	router.Endpoint("/posts").GET(testHandler("posts"))
+	router.Endpoint("/posts").POST(testHandler("create"))
+	router.Endpoint("/posts").Query("draft", "true").GET(testHandler("drafts"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Host("api.example.com").Endpoint("/status").GET(testHandler("status"))
+	if n := router.Len(); n != 5 {
+		t.Errorf("Expected 5 routes, got %d", n)
+	}
+	if n := router.EndpointCount(); n != 4 {
+		t.Errorf("Expected 4 endpoints, got %d", n)
+	}
+	if n := router.PrefixCount(); n != 1 {
+		t.Errorf("Expected 1 prefix, got %d", n)
+	}
+}
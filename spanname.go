@@ -0,0 +1,40 @@
+package trout
+
+import "net/http"
+
+// OtelSpanName returns a name for the tracing span of `r` that follows the
+// OpenTelemetry semantic conventions for HTTP servers: the method of `r`,
+// followed by the pattern of the Endpoint or Prefix it matched, like
+// `GET /posts/{slug}`. If `r` didn't match an Endpoint or Prefix, only the
+// method is returned. Using the pattern instead of the request path keeps the
+// number of distinct span names low.
+//
+// The pattern is only known once `r` has been routed, so OtelSpanName should
+// be called from a handler or Router middleware.
+func OtelSpanName(r *http.Request) string {
+	if pattern := Pattern(r); pattern != "" {
+		return r.Method + " " + pattern
+	}
+	return r.Method
+}
+
+// RenameSpan returns middleware that calls `rename` with each request and its
+// span name, as returned by OtelSpanName, before serving it. With
+// OpenTelemetry, `rename` can set the name of the span started by
+// instrumentation that ran before routing, which can't know the pattern:
+//
+//	trout.RenameSpan(func(r *http.Request, name string) {
+//		trace.SpanFromContext(r.Context()).SetName(name)
+//	})
+//
+// RenameSpan should be used as Router middleware, set using
+// Router.SetMiddleware, so that it runs after the request has been routed and
+// can see the pattern it matched.
+func RenameSpan(rename func(r *http.Request, name string)) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rename(r, OtelSpanName(r))
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenameSpan(t *testing.T) {
+	type testCase struct {
+		method, url, name string
+	}
+	cases := []testCase{
+		{"GET", "/posts/foo", "GET /posts/{slug}"},
+		{"POST", "/posts/foo", "POST /posts/{slug}"},
+		{"GET", "/static/site.css", "GET /static::prefix"},
+		{"GET", "/missing", "GET"},
+	}
+	var name string
+	var router Router
+	router.SetMiddleware(RenameSpan(func(r *http.Request, n string) {
+		name = n
+	}))
+	router.Endpoint("/posts/{slug}").GET(testHandler("post"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	for _, c := range cases {
+		name = "unset"
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if name != c.name {
+			t.Errorf("Expected %s %s to have span name %q, got %q", c.method, c.url, c.name, name)
+		}
+	}
+}
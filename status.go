@@ -0,0 +1,48 @@
+package trout
+
+import "net/http"
+
+// statusResponseWriter is an http.ResponseWriter that records the status
+// code sent, for middleware that reports on responses.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// newStatusResponseWriter returns a statusResponseWriter wrapping `w`.
+func newStatusResponseWriter(w http.ResponseWriter) *statusResponseWriter {
+	return &statusResponseWriter{ResponseWriter: w}
+}
+
+// WriteHeader records `status`, if no status has been recorded yet, and
+// sends it.
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes `b` to the wrapped http.ResponseWriter, recording a 200 status
+// if no status has been recorded yet.
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Status returns the status code that was sent, which is 200 if the handler
+// never set one.
+func (w *statusResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap returns the http.ResponseWriter being wrapped, for use with
+// http.ResponseController.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,42 @@
+package trout
+
+// Swap replaces every Endpoint and Prefix on `router` with the Endpoints and
+// Prefixes `build` defines on the Router passed to it, in a single step. The
+// new routes are defined on a separate, empty Router, so requests can keep
+// being served while `build` runs; requests that are being routed while the
+// swap happens will finish using the old routes. Named routes are replaced
+// along with everything else.
+//
+// Only the Endpoints and Prefixes are swapped; any properties, prefix, or
+// middleware `build` sets on the Router passed to it are ignored.
+//
+// Unlike the other methods for defining Endpoints and Prefixes, Swap is safe
+// to use while `router` is serving requests. Copies of `router` made before
+// any Endpoint or Prefix was defined on it won't see the swapped routes, so
+// Routers that will be swapped should be used through a pointer, or have at
+// least one route defined before they're copied.
+func (router *Router) Swap(build func(*Router)) {
+	var fresh Router
+	build(&fresh)
+	if fresh.trie == nil {
+		fresh.trie = newTrie()
+	}
+	if router.trie == nil {
+		router.trie = newTrie()
+	}
+	router.trie.swap(fresh.trie)
+}
+
+// Reset removes every Endpoint and Prefix from `router`, and sets all of its
+// properties, its prefix, and its middleware back to their zero values, so
+// it's like a new, empty Router. The routes are removed while holding the
+// same lock used to route requests, so requests being routed while Reset
+// runs will use either the old routes or none at all. Routers cloned from
+// `router`, which share its routes, will have their routes removed too.
+func (router *Router) Reset() {
+	t := router.trie
+	if t != nil {
+		t.swap(newTrie())
+	}
+	*router = Router{trie: t}
+}
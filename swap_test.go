@@ -0,0 +1,95 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSwap(t *testing.T) {
+	var router Router
+	router.Endpoint("/old").Handler(testHandler("old"))
+	router.Endpoint("/shared").Name("shared").Handler(testHandler("shared-old"))
+
+	serve := func(url string) string {
+		r, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if body := serve("/shared"); body != "shared-old" && body != "shared-new" {
+				t.Errorf("Unexpected response during swap: %q", body)
+				return
+			}
+		}
+	}()
+	router.Swap(func(r *Router) {
+		r.Endpoint("/new").Handler(testHandler("new"))
+		r.Endpoint("/shared/{id}").Name("shared").Handler(testHandler("unused"))
+		r.Endpoint("/shared").Handler(testHandler("shared-new"))
+	})
+	close(stop)
+	wg.Wait()
+
+	if body := serve("/old"); body != "404 Page Not Found" {
+		t.Errorf("Expected /old to be removed, got %q", body)
+	}
+	if body := serve("/new"); body != "new" {
+		t.Errorf("Expected /new to be added, got %q", body)
+	}
+	if body := serve("/shared"); body != "shared-new" {
+		t.Errorf("Expected /shared to be replaced, got %q", body)
+	}
+	u, err := router.URLFor("shared", "id", "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error building URL: %+v", err)
+	}
+	if u != "/shared/foo" {
+		t.Errorf("Expected named routes to be swapped, got %q", u)
+	}
+}
+
+func TestReset(t *testing.T) {
+	router := NewRouter(WithPrefix("/api"), WithTiming(), WithNotFound(testHandler("custom-404")))
+	router.SetMiddleware(func(h http.Handler) http.Handler { return h })
+	router.Endpoint("/posts").Name("posts").Handler(testHandler("posts"))
+	router.Host("api.example.com").Endpoint("/status").Handler(testHandler("status"))
+
+	router.Reset()
+	if router.Len() != 0 {
+		t.Errorf("Expected no routes after Reset, got %v", router.Routes())
+	}
+	if router.prefix != "" || router.middleware != nil || router.Timing || router.Handle404 != nil {
+		t.Errorf("Expected configuration to be cleared after Reset, got %+v", *router)
+	}
+	if _, err := router.URLFor("posts"); err == nil {
+		t.Errorf("Expected names to be removed by Reset")
+	}
+
+	router.Endpoint("/posts").Handler(testHandler("new-posts"))
+	r, err := http.NewRequest("GET", "/posts", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if body := w.Body.String(); body != "new-posts" {
+		t.Errorf("Expected the Router to be usable after Reset, got %q", body)
+	}
+}
@@ -0,0 +1,38 @@
+package trout
+
+import (
+	"net/http"
+	"time"
+)
+
+// timeoutBody is the body of the response sent when a handler wrapped by
+// Timeout runs out of time.
+const timeoutBody = "503 Service Unavailable"
+
+// Timeout returns middleware that gives each request `d` to be served. The
+// request's context gets a deadline `d` away, and if the handler hasn't
+// finished by then, a 503 response is sent in its place. When the Router's
+// Timing property is set, the time it took to route the request, as reported
+// by Elapsed and the Trout-Timer header, counts against `d`, so the budget
+// covers the whole request.
+//
+// Timeout uses http.TimeoutHandler, so the handler's response is buffered
+// until it finishes, and once the deadline passes, its writes fail with
+// http.ErrHandlerTimeout instead of reaching the client. Handlers should stop
+// working when their request's context is done, as Timeout can't stop them.
+//
+// Timeout is meant to be used as middleware on an Endpoint or Prefix, set
+// using Methods.Middleware, so each one can have its own budget.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			budget := d - Elapsed(r)
+			if budget <= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(timeoutBody)) //nolint:errcheck
+				return
+			}
+			http.TimeoutHandler(h, budget, timeoutBody).ServeHTTP(w, r)
+		})
+	}
+}
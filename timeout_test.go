@@ -0,0 +1,55 @@
+package trout
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	type testCase struct {
+		url  string
+		code int
+		body string
+	}
+	cases := []testCase{
+		{"/fast", http.StatusOK, "fast"},
+		{"/slow", http.StatusServiceUnavailable, "503 Service Unavailable"},
+	}
+	writeErr := make(chan error, 1)
+	served := make(chan struct{})
+	var router Router
+	router.Endpoint("/fast").Methods("GET").Middleware(Timeout(time.Second)).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Errorf("Expected the request context to have a deadline")
+		}
+		w.Write([]byte("fast"))
+	})
+	router.Endpoint("/slow").Methods("GET").Middleware(Timeout(10 * time.Millisecond)).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// wait until the 503 has been sent, as TimeoutHandler only
+		// marks the request as timed out after canceling its context
+		<-served
+		_, err := w.Write([]byte("slow"))
+		writeErr <- err
+	})
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %s to return %d, got %d", c.url, c.code, w.Code)
+		}
+		if body := w.Body.String(); body != c.body {
+			t.Errorf("Expected %s to have a body of %q, got %q", c.url, c.body, body)
+		}
+	}
+	close(served)
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("Expected writing after the timeout to fail with %v, got %v", http.ErrHandlerTimeout, err)
+	}
+}
@@ -1,7 +1,11 @@
 package trout
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -13,9 +17,30 @@ type key struct {
 	// dynamic signifies whether the text is a placeholder for another
 	// value or is what we're trying to match against
 	dynamic bool
+	// before and after are the literal text, lowercased, that surrounds
+	// a dynamic key in its piece, like "img-" and ".png" in
+	// "img-{name}.png"
+	before, after string
+	// caseSensitive signifies whether the literal text around a
+	// dynamic key and its list of allowed values should be matched
+	// exactly, rather than case-insensitively
+	caseSensitive bool
 	// prefix signifies whether a key should be considered a prefix
 	// matcher, matching all subsequent keys
 	prefix bool
+	// constraint is the source of the regular expression (or shortcut),
+	// or the list of allowed values, that a dynamic key was constrained
+	// with, if any
+	constraint string
+	// re is the compiled form of constraint, anchored to match the whole
+	// piece
+	re *regexp.Regexp
+	// enum holds the allowed values, lowercased, when constraint is a
+	// list of values separated by "|" instead of a regular expression
+	enum []string
+	// catchAll signifies whether a dynamic key should capture every
+	// remaining piece of the path, rather than just a single piece
+	catchAll bool
 	// nul signifies whether a key should be considered a null key, used to
 	// terminate an endpoint, or whether other keys follow it
 	nul bool
@@ -30,9 +55,21 @@ func (k key) equals(other key) bool {
 	if k.dynamic != other.dynamic {
 		return false
 	}
+	if k.before != other.before || k.after != other.after {
+		return false
+	}
+	if k.caseSensitive != other.caseSensitive {
+		return false
+	}
 	if k.prefix != other.prefix {
 		return false
 	}
+	if k.constraint != other.constraint {
+		return false
+	}
+	if k.catchAll != other.catchAll {
+		return false
+	}
 	if k.nul != other.nul {
 		return false
 	}
@@ -42,25 +79,112 @@ func (k key) equals(other key) bool {
 // String fulfills the Stringer interface, returning a representation of `k`
 // that can be used as a string. nul keys will be represented by "{::NULL:}",
 // while dynamic keys will be surrounded by "{" and "}" and prefix keys will
-// end in "::prefix"}. Static keys will be displayed as normal.
+// end in "::prefix"}. catchAll keys will end in "...", and constrained keys
+// will end in ":" and their constraint, inside the braces.
+// Any literal text around a dynamic key is kept outside the braces.
+// Static keys will be displayed as normal, with any literal braces escaped
+// as `{{` and `}}`, the same way they're written in URL templates.
 func (k key) String() string {
 	if k.nul {
 		return "{::NULL::}"
 	}
 	res := ""
 	if k.dynamic {
+		res += braceEscaper.Replace(k.before)
 		res += "{"
+		res += k.value
+	} else {
+		res += braceEscaper.Replace(k.value)
+	}
+	if k.catchAll {
+		res += "..."
+	}
+	if k.constraint != "" {
+		res += ":" + k.constraint
 	}
-	res += k.value
 	if k.prefix {
 		res += "::prefix"
 	}
 	if k.dynamic {
 		res += "}"
+		res += braceEscaper.Replace(k.after)
 	}
 	return res
 }
 
+// mixed returns whether `k` is a dynamic key with literal text around it in
+// its piece.
+func (k key) mixed() bool {
+	return k.before != "" || k.after != ""
+}
+
+// matches returns whether `piece` can be matched by the dynamic key `k`,
+// checking any literal text around the key and its constraint.
+func (k key) matches(piece string) bool {
+	if k.mixed() {
+		var ok bool
+		piece, ok = k.trim(piece)
+		if !ok || piece == "" {
+			return false
+		}
+	}
+	return k.allows(piece)
+}
+
+// matchesPath returns whether the dynamic key `k` matches the start of `path`:
+// its first piece, or, for a catch-all key, every piece of it joined by `/`,
+// which is the value it would capture.
+func (k key) matchesPath(path []string) bool {
+	if k.catchAll && (k.re != nil || k.enum != nil) {
+		return k.allows(strings.Join(path, "/"))
+	}
+	return k.matches(path[0])
+}
+
+// allows returns whether `val` satisfies the constraint of `k`, if it has
+// one. Values are compared to an enum constraint case-insensitively, unless
+// `k` is case-sensitive.
+func (k key) allows(val string) bool {
+	if k.enum != nil {
+		for _, allowed := range k.enum {
+			if k.equalText(val, allowed) {
+				return true
+			}
+		}
+		return false
+	}
+	return k.re == nil || k.re.MatchString(val)
+}
+
+// trim returns the part of `piece` matched by the dynamic key `k`, without
+// the literal text around it. The literal text is compared
+// case-insensitively, unless `k` is case-sensitive. If `piece` doesn't start
+// and end with the literal text, false is returned.
+func (k key) trim(piece string) (string, bool) {
+	if len(piece) < len(k.before)+len(k.after) {
+		return "", false
+	}
+	if !k.equalText(piece[:len(k.before)], k.before) {
+		return "", false
+	}
+	if !k.equalText(piece[len(piece)-len(k.after):], k.after) {
+		return "", false
+	}
+	return piece[len(k.before) : len(piece)-len(k.after)], true
+}
+
+// equalText returns whether `a` and `b` are the same text, ignoring case
+// unless `k` is case-sensitive.
+func (k key) equalText(a, b string) bool {
+	if k.caseSensitive {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+// braceEscaper escapes the literal braces in static keys.
+var braceEscaper = strings.NewReplacer("{", "{{", "}", "}}")
+
 // node represents a single part of an endpoint or URL within our router. If a
 // URL is split by /, each piece is a node, and each piece is the child of the
 // node that came before it. This allows us to build a trie of these pieces
@@ -73,8 +197,158 @@ type node struct {
 	terminator   *node
 	children     map[string]*node
 	wildChildren []*node
-	methods      map[string]http.Handler
-	middleware   map[string][]func(http.Handler) http.Handler
+	// prefixChildren holds the static children whose keys are prefixes,
+	// by value. They're kept apart from children, so a Prefix and an
+	// Endpoint for the same path element each get their own node, and
+	// a Prefix never has anything beneath it.
+	prefixChildren map[string]*node
+	// segments holds the static path elements a node matches, when a
+	// chain of static path elements with nothing branching off them has
+	// been compacted into a single node. Its value is then the segments
+	// joined by /, its depth is the depth of the last segment, and it's
+	// stored in its parent's children under the first segment.
+	segments []string
+	// methods holds the handlers of a terminator, by method. It should
+	// only be changed using setMethod, which keeps methodList up to
+	// date.
+	methods map[string]http.Handler
+	// methodList holds the methods in methods, sorted, not including
+	// the catch-all method, so they don't have to be gathered for every
+	// request. It's shared with the routes for requests a terminator
+	// matches, and must never be modified, only replaced.
+	methodList []string
+	middleware map[string][]func(http.Handler) http.Handler
+	// templatePath is the path to a terminator, as returned by
+	// pathString, which is computed when the terminator is created, as
+	// it never changes, not even when the nodes above it are split
+	templatePath string
+	// trailingSlash is set on terminators whose template was first
+	// defined with a trailing slash
+	trailingSlash bool
+	// groupMiddleware is the middleware of the Group a terminator was
+	// defined through, if any, which runs before the rest of its
+	// middleware
+	groupMiddleware []func(http.Handler) http.Handler
+	// priority is the priority a terminator was given, which beats any
+	// score when picking a node
+	priority int
+	// excludedMethods holds the methods a terminator's catch-all handler
+	// was set not to serve using MethodsExcept, if any
+	excludedMethods map[string]bool
+	// name is the name a terminator was given, if any
+	name string
+	// notFound and methodNotAllowed are the handlers a terminator was
+	// given for 404 and 405 responses to requests at or under it, if any
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+	// query holds the query parameters a terminator created by
+	// Endpoint.Query requires, sorted. queryBase is the terminator in the
+	// trie it was created from, which holds all the terminators created
+	// from it in queryVariants.
+	query         []queryMatch
+	queryBase     *node
+	queryVariants []*node
+	// names holds the terminators that have been named, by name. It is
+	// only set on root nodes, and is shared by the root node of a trie
+	// and the root nodes for its host templates.
+	names map[string]*node
+	// host is the host template a root node holds the routes for, if
+	// any
+	host string
+}
+
+// setMethod sets `h` as the handler for `method` on the terminator `n`, and
+// rebuilds the sorted list of its methods.
+func (n *node) setMethod(method string, h http.Handler) {
+	n.methods[method] = h
+	if method == catchAllMethod {
+		return
+	}
+	methods := make([]string, 0, len(n.methods))
+	for m := range n.methods {
+		if m != catchAllMethod {
+			methods = append(methods, m)
+		}
+	}
+	sort.Strings(methods)
+	n.methodList = methods
+}
+
+// root returns the root node of the trie `n` belongs to.
+func (n *node) root() *node {
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+// setName records `name` as the name of `n`, which must be a terminator,
+// returning an error if another node in the trie already has that name.
+func (n *node) setName(name string) error {
+	root := n.root()
+	if existing, ok := root.names[name]; ok && existing != n {
+		return fmt.Errorf("trout: a route named %q already exists: %s", name, pathString(existing))
+	}
+	if root.names == nil {
+		root.names = map[string]*node{}
+	}
+	if n.name != "" {
+		delete(root.names, n.name)
+	}
+	n.name = name
+	root.names[name] = n
+	return nil
+}
+
+// span returns the number of path elements `n` matches.
+func (n *node) span() int {
+	if len(n.segments) > 1 {
+		return len(n.segments)
+	}
+	return 1
+}
+
+// firstSegment returns the first path element `n` matches, which is the key
+// it's stored under in its parent's children.
+func (n *node) firstSegment() string {
+	if len(n.segments) > 1 {
+		return n.segments[0]
+	}
+	return n.value.value
+}
+
+// staticChildren returns the static children of `n`, including its prefix
+// children, in the order of their keys, with a child that isn't a prefix
+// coming before a prefix child with the same key.
+func (n *node) staticChildren() []*node {
+	keys := make([]string, 0, len(n.children)+len(n.prefixChildren))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	for k := range n.prefixChildren {
+		if _, ok := n.children[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	children := make([]*node, 0, len(n.children)+len(n.prefixChildren))
+	for _, k := range keys {
+		if child, ok := n.children[k]; ok {
+			children = append(children, child)
+		}
+		if child, ok := n.prefixChildren[k]; ok {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// allSegments returns the path elements the static node `n` matches.
+func (n *node) allSegments() []string {
+	if len(n.segments) > 1 {
+		return n.segments
+	}
+	return []string{n.value.value}
 }
 
 // newChild inserts a new child node under `n` and
@@ -89,148 +363,621 @@ func (n *node) newChild(value key, term bool) *node {
 		middleware: map[string][]func(http.Handler) http.Handler{},
 		parent:     n,
 	}
-	if value.dynamic {
+	switch {
+	case value.dynamic:
 		n.wildChildren = append(n.wildChildren, newNode)
-	} else if term {
+	case term:
 		n.terminator = newNode
-	} else {
+	case value.prefix:
+		if n.prefixChildren == nil {
+			n.prefixChildren = map[string]*node{}
+		}
+		n.prefixChildren[value.value] = newNode
+	default:
 		n.children[value.value] = newNode
 	}
+	if term {
+		newNode.templatePath = pathString(newNode)
+	}
 	return newNode
 }
 
+// newStaticChain inserts a single new child node under `n` that matches all
+// of `segments`, which must be the values of static, non-prefix keys, and
+// returns the child.
+func (n *node) newStaticChain(segments []string) *node {
+	newNode := n.newChild(key{value: segments[0]}, false)
+	newNode.setSegments(segments)
+	newNode.depth = n.depth + len(segments)
+	return newNode
+}
+
+// split breaks the compacted node `n` in two after its first `at` segments,
+// so that new nodes can branch off at that point. The first `at` segments
+// are moved into a new node that takes `n`'s place in its parent, and `n`
+// keeps the rest of its segments, along with its children, wild children, and
+// terminator. The new node is returned.
+func (n *node) split(at int) *node {
+	upper := &node{
+		depth:      n.depth - len(n.segments) + at,
+		children:   map[string]*node{},
+		methods:    map[string]http.Handler{},
+		middleware: map[string][]func(http.Handler) http.Handler{},
+		parent:     n.parent,
+	}
+	upper.setSegments(n.segments[:at])
+	n.parent.children[upper.firstSegment()] = upper
+	n.setSegments(n.segments[at:])
+	n.parent = upper
+	upper.children[n.firstSegment()] = n
+	return upper
+}
+
+// setSegments sets the static path elements `n` matches.
+func (n *node) setSegments(segments []string) {
+	n.value = key{value: strings.Join(segments, "/")}
+	n.segments = nil
+	if len(segments) > 1 {
+		n.segments = append([]string(nil), segments...)
+	}
+}
+
 // trie is the data structure holding all our nodes. It will be used as the
 // main data structure of our router.
 type trie struct {
 	root *node
+	// hosts holds the root nodes for the routes that are only matched
+	// for requests to certain hosts, in the order their host templates
+	// were added
+	hosts []*hostRoot
 	sync.RWMutex
 }
 
-// add inserts the nodes necessary to construct the supplied path.
-func (t *trie) add(path []key, methods map[string]http.Handler) *node {
-	n := t.root
+// hostRoot is the root node for the routes of a host template, along with
+// the keys the template was parsed into, one per label.
+type hostRoot struct {
+	labels []key
+	root   *node
+}
+
+// newTrie returns an empty trie, ready to have nodes added to it.
+func newTrie() *trie {
+	return &trie{
+		root: &node{
+			children: map[string]*node{},
+			names:    map[string]*node{},
+		},
+	}
+}
+
+// roots returns the root node of `t`, followed by the root nodes for each
+// of its host templates.
+func (t *trie) roots() []*node {
+	roots := make([]*node, 0, len(t.hosts)+1)
+	roots = append(roots, t.root)
+	for _, h := range t.hosts {
+		roots = append(roots, h.root)
+	}
+	return roots
+}
+
+// swap replaces the nodes in `t` with the nodes in `other`. Anything still
+// using the nodes that were in `t` can safely keep doing so, as nodes are
+// never modified by swap.
+func (t *trie) swap(other *trie) {
+	other.RLock()
+	root, hosts := other.root, other.hosts
+	other.RUnlock()
 
 	t.Lock()
 	defer t.Unlock()
+	t.root = root
+	t.hosts = hosts
+}
 
-	for _, piece := range path {
+// add inserts the nodes necessary to construct the supplied path, returning
+// the terminating node for the path and whether that node was newly created.
+func (t *trie) add(path []key) (*node, bool) {
+	t.Lock()
+	defer t.Unlock()
+	return insert(t.root, path)
+}
+
+// addHost works like add, but inserts the nodes under the root node for the
+// host template `labels`, creating it if necessary.
+func (t *trie) addHost(labels, path []key) (*node, bool) {
+	t.Lock()
+	defer t.Unlock()
+	return insert(t.hostRoot(labels), path)
+}
+
+// hostRoot returns the root node for the host template `labels`, creating
+// it if `t` doesn't have one yet.
+func (t *trie) hostRoot(labels []key) *node {
+	for _, h := range t.hosts {
+		if equalKeys(h.labels, labels) {
+			return h.root
+		}
+	}
+	host := make([]string, 0, len(labels))
+	for _, label := range labels {
+		host = append(host, label.String())
+	}
+	root := &node{
+		children: map[string]*node{},
+		names:    t.root.names,
+		host:     strings.Join(host, "."),
+	}
+	t.hosts = append(t.hosts, &hostRoot{labels: labels, root: root})
+	return root
+}
+
+// equalKeys returns whether each of `a` is equivalent to the key at the same
+// position in `b`.
+func equalKeys(a, b []key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].equals(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// insert adds the nodes necessary to construct `path` under the root node
+// `n`, returning the terminating node for the path and whether that node was
+// newly created.
+func insert(n *node, path []key) (*node, bool) {
+	for len(path) > 0 {
+		piece := path[0]
 		var match bool
-		if !piece.dynamic {
+		if !piece.dynamic && piece.prefix {
+			// prefixes are never compacted, so they only ever
+			// match a single piece
+			if prefix, ok := n.prefixChildren[piece.value]; ok {
+				n = prefix
+				path = path[1:]
+				match = true
+			}
+		} else if !piece.dynamic {
 			if static, ok := n.children[piece.value]; ok {
+				// a compacted node may only match some of the
+				// pieces we have left, in which case it needs
+				// to be split where they diverge
+				matched := matchSegments(static, path)
+				if matched < static.span() {
+					static = static.split(matched)
+				}
 				n = static
+				path = path[matched:]
 				match = true
 			}
 		} else {
+			// reuse any wild child with an identical key, so
+			// defining the same template more than once doesn't
+			// leave findNodes more wild children to try
 			for _, wild := range n.wildChildren {
 				if wild.value.equals(piece) {
 					n = wild
+					path = path[1:]
 					match = true
 					break
 				}
 			}
 		}
-		if !match {
-			n = n.newChild(piece, false)
+		if match {
+			continue
+		}
+		// compact any run of static pieces we're adding into a
+		// single node
+		if run := staticRun(path); len(run) > 0 {
+			n = n.newStaticChain(run)
+			path = path[len(run):]
+			continue
 		}
+		n = n.newChild(piece, false)
+		path = path[1:]
 	}
 	if n.terminator != nil {
-		return n.terminator
+		return n.terminator, false
 	}
 	n = n.newChild(key{nul: true}, true)
+	return n, true
+}
+
+// remove removes the terminator for `path`, and any terminators created from
+// it using Endpoint.Query, from under the root node for the host template
+// `labels`, or the root node of `t` if `labels` is nil. Any nodes left with
+// nothing under them are removed too. It returns whether there was a
+// terminator to remove.
+func (t *trie) remove(labels, path []key) bool {
+	t.Lock()
+	defer t.Unlock()
+	root := t.root
+	if labels != nil {
+		root = nil
+		for _, h := range t.hosts {
+			if equalKeys(h.labels, labels) {
+				root = h.root
+				break
+			}
+		}
+	}
+	n := lookup(root, path)
+	if n == nil || n.terminator == nil {
+		return false
+	}
+	for _, term := range append([]*node{n.terminator}, n.terminator.queryVariants...) {
+		if term.name != "" && root.names[term.name] == term {
+			delete(root.names, term.name)
+		}
+	}
+	n.terminator = nil
+	for n.parent != nil && n.terminator == nil && len(n.children) < 1 && len(n.wildChildren) < 1 && len(n.prefixChildren) < 1 {
+		parent := n.parent
+		if n.value.prefix && !n.value.dynamic {
+			delete(parent.prefixChildren, n.value.value)
+		} else if n.value.dynamic {
+			// make a new slice, instead of modifying the old
+			// one, in case anything is still holding on to it
+			wild := make([]*node, 0, len(parent.wildChildren)-1)
+			for _, child := range parent.wildChildren {
+				if child != n {
+					wild = append(wild, child)
+				}
+			}
+			parent.wildChildren = wild
+		} else {
+			delete(parent.children, n.firstSegment())
+		}
+		n = parent
+	}
+	return true
+}
+
+// lookup returns the node under `n` that the terminator for `path` would be
+// added to, following the same rules as `insert`, or nil if there isn't one.
+func lookup(n *node, path []key) *node {
+	for n != nil && len(path) > 0 {
+		piece := path[0]
+		var next *node
+		if !piece.dynamic && piece.prefix {
+			if prefix, ok := n.prefixChildren[piece.value]; ok {
+				next = prefix
+				path = path[1:]
+			}
+		} else if !piece.dynamic {
+			if static, ok := n.children[piece.value]; ok && matchSegments(static, path) == static.span() {
+				next = static
+				path = path[static.span():]
+			}
+		} else {
+			for _, wild := range n.wildChildren {
+				if wild.value.equals(piece) {
+					next = wild
+					path = path[1:]
+					break
+				}
+			}
+		}
+		n = next
+	}
 	return n
 }
 
-// findNodes runs the findNodes function on the root node of `t`
-// with concurrency safety.
-func (t *trie) findNodes(path []string) []*node {
+// matchSegments returns how many of the path elements of the static node `n`
+// are matched by the start of `path`. The first path element is assumed to
+// match, as that's how `n` was found.
+func matchSegments(n *node, path []key) int {
+	matched := 1
+	for ; matched < len(n.segments) && matched < len(path); matched++ {
+		if path[matched].dynamic || path[matched].prefix || path[matched].value != n.segments[matched] {
+			break
+		}
+	}
+	return matched
+}
+
+// staticRun returns the values of the static keys at the start of `path`
+// that can be compacted into a single node. Prefix keys are never compacted,
+// so they're left to get their own node.
+func staticRun(path []key) []string {
+	var run []string
+	for _, piece := range path {
+		if piece.dynamic || piece.prefix {
+			break
+		}
+		run = append(run, piece.value)
+	}
+	return run
+}
+
+// findHostNodes finds the nodes that could match the supplied
+// input for a request to `host`, with concurrency safety. The
+// root nodes for host templates that match `host` are tried
+// first, best match first, falling back to the root node of
+// `t` if none of them have any matches. The parameters the
+// matching host template captured are returned along with the
+// nodes. `match` is passed on to findNodes.
+func (t *trie) findHostNodes(host string, path, folded []string, match func(key, piece string) bool) ([]*node, []Param) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.hostNodes(host, path, folded, match)
+}
+
+// pickNode finds the nodes that could match the supplied input
+// for a request to `host`, like findHostNodes, and picks the
+// terminator that should serve a request using `method` from
+// them, using the pickNode function. Both happen while holding
+// the read lock, so the terminator can't be removed while it's
+// being picked.
+func (t *trie) pickNode(host string, path, folded []string, match func(key, piece string) bool, method string, query *requestQuery) (*node, []Param) {
 	t.RLock()
 	defer t.RUnlock()
-	return findNodes(t.root, path)
+	nodes, params := t.hostNodes(host, path, folded, match)
+	return pickNode(nodes, method, query), params
+}
+
+// hostNodes does the work of findHostNodes, without taking
+// the read lock.
+func (t *trie) hostNodes(host string, path, folded []string, match func(key, piece string) bool) ([]*node, []Param) {
+	if len(t.hosts) > 0 {
+		for _, m := range matchHosts(t.hosts, host) {
+			if nodes := findNodes(m.root, path, folded, match); len(nodes) > 0 {
+				return nodes, m.params
+			}
+		}
+	}
+	return findNodes(t.root, path, folded, match), nil
 }
 
 // findNodes returns all terminating nodes that could match the
 // supplied input. Because of wildcards and prefixes, there may
 // be multiple results, and it's up to the caller to determine
 // which is best.
-func findNodes(n *node, path []string) []*node {
-	if n == nil {
+//
+// `folded` should be `path` with each piece lowercased; it's
+// used to match static nodes, while `path` is used to match
+// constraints on dynamic nodes. If `match` isn't nil, it's
+// used to match static nodes against `path` instead; see
+// staticMatches.
+func findNodes(n *node, path, folded []string, match func(key, piece string) bool) []*node {
+	// the Router always splits the path into at least one piece, with
+	// the root being a single empty piece, so an empty path can't
+	// match anything
+	if n == nil || len(path) < 1 {
 		return nil
 	}
-	var results []*node
-	if n.value.prefix {
-		return []*node{n}
+	// nodes are visited depth-first, static children before wild
+	// children, so the results are always in the same order. Each step
+	// holds a node and how many pieces of the path it and its ancestors
+	// matched.
+	type step struct {
+		n      *node
+		offset int
 	}
-	var nextPath []string
-	if len(path) > 1 {
-		nextPath = path[1:]
-	}
-	static, ok := n.children[path[0]]
-	if ok {
-		if len(nextPath) < 1 {
-			if static.terminator != nil {
-				results = append(results, static)
+	var buf [16]step
+	work := append(buf[:0], step{n: n})
+	results := make([]*node, 0, 4)
+	for len(work) > 0 {
+		cur := work[len(work)-1]
+		work = work[:len(work)-1]
+		n, offset := cur.n, cur.offset
+
+		// nodes that used up the rest of the path are results if
+		// they terminate a route; catchAll nodes use up the rest of
+		// the path no matter how much of it is left
+		if offset >= len(path) || n.value.catchAll {
+			if n.terminator != nil {
+				results = append(results, n)
 			}
-		} else {
-			staticResults := findNodes(static, nextPath)
-			if staticResults != nil {
-				results = append(results, staticResults...)
+			continue
+		}
+		// prefixes match anything left in the path. Nothing is ever
+		// defined beneath a prefix, as templates that extend it are
+		// stored alongside it instead, so there's nothing more to
+		// find here; any deeper routes that match are found through
+		// the prefix's siblings, and beat it by being deeper.
+		if n.value.prefix {
+			results = append(results, n)
+			continue
+		}
+
+		// push the wild children in reverse, so they're popped in
+		// the order they were added, after the static child
+		for i := len(n.wildChildren) - 1; i >= 0; i-- {
+			wild := n.wildChildren[i]
+			if !wild.value.matchesPath(path[offset:]) {
+				continue
 			}
+			work = append(work, step{n: wild, offset: offset + 1})
+		}
+		// push the static children in reverse too, so they're
+		// popped before the wild children, in order
+		var sbuf [2]*node
+		statics := staticMatches(sbuf[:0], n, path[offset:], folded[offset:], match)
+		for i := len(statics) - 1; i >= 0; i-- {
+			work = append(work, step{n: statics[i], offset: offset + statics[i].span()})
 		}
 	}
-	for _, wild := range n.wildChildren {
-		if len(nextPath) < 1 {
-			if wild.terminator != nil {
-				results = append(results, wild)
-			}
+	return results
+}
+
+// staticMatches appends the static children of `n` that match the start of
+// `path` to `dst`, and returns it. Without a `match` function, that's the
+// child stored under the first piece of `folded`, if all of its segments
+// match `folded`, and the prefix child stored under it; there can't be any
+// others. With one, every child is tried, in the order staticChildren
+// returns them, and the ones for which `match` returns true for each of
+// their segments and the piece of `path` it lines up with are appended.
+func staticMatches(dst []*node, n *node, path, folded []string, match func(key, piece string) bool) []*node {
+	if match == nil {
+		static, ok := n.children[folded[0]]
+		// compacted nodes need to match as many pieces as they
+		// have segments
+		if ok && (len(static.segments) < 2 || hasSegments(folded, static.segments)) {
+			dst = append(dst, static)
+		}
+		if prefix, ok := n.prefixChildren[folded[0]]; ok {
+			dst = append(dst, prefix)
+		}
+		return dst
+	}
+	for _, static := range n.staticChildren() {
+		segments := static.allSegments()
+		if len(path) < len(segments) {
 			continue
 		}
-		wildResults := findNodes(wild, nextPath)
-		if wildResults != nil {
-			results = append(results, wildResults...)
+		matched := true
+		for i, segment := range segments {
+			if !match(segment, path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			dst = append(dst, static)
 		}
 	}
-	return results
+	return dst
 }
 
-// vars runs the vars function with concurrency safety as long
-// as `n` is a descendent of the root node of `t`.
-func (t *trie) vars(n *node, input []string) map[string][]string {
+// closestNode runs the closestNode function with concurrency
+// safety, on the root node for the host template that best
+// matches `host`, or the root node of `t` if none do. `match` is
+// passed on to closestNode.
+func (t *trie) closestNode(host string, path, folded []string, match func(key, piece string) bool) *node {
 	t.RLock()
 	defer t.RUnlock()
-	return vars(n, input)
+	root := t.root
+	if matches := matchHosts(t.hosts, host); len(matches) > 0 {
+		root = matches[0].root
+	}
+	return closestNode(root, path, folded, match)
 }
 
-// vars returns a mapping of dynamic path key names to
-// the values assigned to them. Values assigned to them
-// should be in the order they appear in the input when
-// key names are reused within a single path.
-func vars(n *node, input []string) map[string][]string {
-	if len(input) < 1 {
-		return map[string][]string{}
+// closestNode returns the deepest node that can be reached
+// from `n` by following `path`, preferring static children
+// to wild children at each step, without backtracking. It
+// stops at prefix nodes, and doesn't need a terminator to
+// be found at the end of the path. Static children are
+// matched using staticMatches.
+func closestNode(n *node, path, folded []string, match func(key, piece string) bool) *node {
+	offset := 0
+	for offset < len(path) && !n.value.prefix {
+		var sbuf [2]*node
+		if statics := staticMatches(sbuf[:0], n, path[offset:], folded[offset:], match); len(statics) > 0 {
+			static := statics[0]
+			n = static
+			offset += static.span()
+			continue
+		}
+		var next *node
+		for _, wild := range n.wildChildren {
+			if wild.value.matchesPath(path[offset:]) {
+				next = wild
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		n = next
+		offset++
 	}
-	if n == nil {
-		return map[string][]string{}
+	return n
+}
+
+// hasSegments returns true if `folded` starts with all of `segments`.
+func hasSegments(folded, segments []string) bool {
+	if len(folded) < len(segments) {
+		return false
+	}
+	for i, segment := range segments {
+		if folded[i] != segment {
+			return false
+		}
 	}
-	if n.value.nul {
+	return true
+}
+
+// orderedVars runs the orderedVars function with
+// concurrency safety as long as `n` is a descendent of the
+// root node of `t`.
+func (t *trie) orderedVars(n *node, input []string) []Param {
+	t.RLock()
+	defer t.RUnlock()
+	return orderedVars(n, input)
+}
+
+// orderedVars returns the dynamic path key names and the
+// values assigned to them, in the order they appear in the
+// input.
+//
+// Each node consumes the piece of the input at its depth;
+// catchAll nodes consume that piece and every piece after
+// it, and prefix nodes ignore anything after their piece.
+func orderedVars(n *node, input []string) []Param {
+	if n != nil && n.value.nul {
 		n = n.parent
 	}
-	if n == nil {
-		return map[string][]string{}
+	var count int
+	for a := n; a != nil; a = a.parent {
+		if a.value.dynamic {
+			count++
+		}
 	}
-	params := vars(n.parent, input[:len(input)-1])
-	if n.value.dynamic {
-		params[n.value.value] = append(params[n.value.value], input[len(input)-1])
+	if count < 1 {
+		return nil
+	}
+	// walk up from the node, filling in the params from the end
+	params := make([]Param, count)
+	for ; n != nil; n = n.parent {
+		if !n.value.dynamic {
+			continue
+		}
+		count--
+		if n.depth < 1 || len(input) < n.depth {
+			continue
+		}
+		val := input[n.depth-1]
+		if n.value.catchAll {
+			val = strings.Join(input[n.depth-1:], "/")
+		} else if n.value.mixed() {
+			val, _ = n.value.trim(val)
+		}
+		params[count] = Param{Name: n.value.value, Value: val}
 	}
 	return params
 }
 
-// pathString runs the pathString function with concurrency
-// safety as long as `n` is a descendent of the root node of
-// `t`.
-func (t *trie) pathString(n *node) string {
+// pattern runs the pattern function with concurrency safety
+// as long as `n` is a descendent of a root node of `t`.
+func (t *trie) pattern(prefix string, n *node) string {
 	t.RLock()
 	defer t.RUnlock()
-	return pathString(n)
+	return pattern(prefix, n)
+}
+
+// pattern returns the URL template of the route `n` is the
+// terminator for, as it's set in the Trout-Pattern header:
+// the host template it was defined for, if any, followed by
+// `prefix`, the prefix of the Router, and the path to `n`.
+func pattern(prefix string, n *node) string {
+	if n == nil {
+		return ""
+	}
+	path := n.templatePath
+	if !n.term {
+		path = pathString(n)
+	}
+	host := n.root().host
+	if host == "" && (prefix == "" || prefix == "/") {
+		return path
+	}
+	return host + strings.TrimSuffix(prefix, "/") + path
 }
 
 // pathString returns a representation of the path to
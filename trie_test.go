@@ -0,0 +1,185 @@
+package trout
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStaticCompaction(t *testing.T) {
+	var router Router
+	router.Endpoint("/api/v1/users/list").Handler(testHandler("list"))
+
+	root := router.trie.root
+	chain, ok := root.children["api"]
+	if !ok {
+		t.Fatalf("Expected a child for api, got %+v", root.children)
+	}
+	if expected := []string{"api", "v1", "users", "list"}; !reflect.DeepEqual(chain.segments, expected) {
+		t.Errorf("Expected static chain to be compacted to %v, got %v", expected, chain.segments)
+	}
+	if chain.depth != 4 {
+		t.Errorf("Expected compacted node to have depth 4, got %d", chain.depth)
+	}
+
+	// adding routes that diverge partway through the chain should split it
+	router.Endpoint("/api/v1/users/{id}").Handler(testHandler("user"))
+	router.Endpoint("/api/v1").Handler(testHandler("v1"))
+	recent := router.Endpoint("/api/v1/posts/recent")
+	recent.Handler(testHandler("recent"))
+
+	upper := root.children["api"]
+	if expected := []string{"api", "v1"}; !reflect.DeepEqual(upper.segments, expected) {
+		t.Errorf("Expected split node to have segments %v, got %v", expected, upper.segments)
+	}
+	if upper.terminator == nil {
+		t.Errorf("Expected split node to have a terminator")
+	}
+	users := upper.children["users"]
+	if users == nil || users.value.value != "users" || len(users.segments) != 0 || users.depth != 3 {
+		t.Errorf("Expected a single users node at depth 3, got %+v", users)
+	}
+	if users != nil && users.children["list"] != chain {
+		t.Errorf("Expected the original node to be kept for the end of the chain")
+	}
+	if posts := upper.children["posts"]; posts == nil || !reflect.DeepEqual(posts.segments, []string{"posts", "recent"}) {
+		t.Errorf("Expected posts/recent to be compacted, got %+v", posts)
+	}
+
+	type testCase struct {
+		url, handler string
+	}
+	cases := []testCase{
+		{"/api/v1/users/list", "list"},
+		{"/API/v1/Users/LIST", "list"},
+		{"/api/v1/users/123", "user"},
+		{"/api/v1", "v1"},
+		{"/api/v1/posts/recent", "recent"},
+		{"/api/v1/posts", "404"},
+		{"/api/v1/posts/recent/more", "404"},
+		{"/api", "404"},
+	}
+	router.Handle404 = testHandler("404")
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s: %+v", c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route %s to %s, routed to %s", c.url, c.handler, res)
+		}
+	}
+
+	if u, err := recent.URL(nil); err != nil || u != "/api/v1/posts/recent" {
+		t.Errorf("Expected URL to be /api/v1/posts/recent, got %q (%v)", u, err)
+	}
+	if res := pathString((*node)(recent)); res != "/api/v1/posts/recent" {
+		t.Errorf("Expected pattern to be /api/v1/posts/recent, got %q", res)
+	}
+	if m := router.Match("GET", "/api/v1/users/list"); m.Pattern != "/api/v1/users/list" {
+		t.Errorf("Expected the pattern of a split chain to be kept, got %q", m.Pattern)
+	}
+}
+
+func TestWildChildrenDeduplicated(t *testing.T) {
+	var router Router
+	router.Endpoint("/posts/{id}").GET(testHandler("get"))
+	router.Endpoint("/posts/{id}").POST(testHandler("post"))
+	router.Endpoint("/posts/{id:int}").Handler(testHandler("int"))
+	router.Endpoint("/posts/{id:int}/comments").Handler(testHandler("comments"))
+
+	posts := router.trie.root.children["posts"]
+	if posts == nil {
+		t.Fatalf("Expected a posts node")
+	}
+	if len(posts.wildChildren) != 2 {
+		t.Fatalf("Expected 2 wild children, one for {id} and one for {id:int}, got %d", len(posts.wildChildren))
+	}
+	if methods := posts.wildChildren[0].terminator.methods; len(methods) != 2 {
+		t.Errorf("Expected both methods to be set on the same terminator, got %v", methods)
+	}
+}
+
+func TestFindNodes(t *testing.T) {
+	var router Router
+	router.Endpoint("/x/a").Handler(testHandler("static"))
+	router.Endpoint("/{y}/a/b").Handler(testHandler("dynamic"))
+	router.Endpoint("/x/{z}/b").Handler(testHandler("mixed"))
+	router.Prefix("/{p}").Handler(testHandler("prefix"))
+
+	expected := []string{"/x/{z}/b", "/{y}/a/b", "/{p::prefix}"}
+	nodes := findNodes(router.trie.root, []string{"x", "a", "b"}, []string{"x", "a", "b"}, nil)
+	var patterns []string
+	for _, n := range nodes {
+		patterns = append(patterns, pathString(n))
+	}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("Expected nodes %v, got %v", expected, patterns)
+	}
+}
+
+func BenchmarkFindNodesDeep(b *testing.B) {
+	var router Router
+	var path strings.Builder
+	pieces := make([]string, 0, 15)
+	for i := 0; i < 15; i++ {
+		piece := fmt.Sprintf("p%d", i)
+		pieces = append(pieces, piece)
+		path.WriteString("/" + piece)
+		// leave a dynamic alternative at every level, so the
+		// search has to branch
+		router.Endpoint(strings.Repeat("/{v}", i+1)).Handler(testHandler("dynamic"))
+		router.Endpoint(path.String()).Handler(testHandler("static"))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		findNodes(router.trie.root, pieces, pieces, nil)
+	}
+}
+
+func TestMethodList(t *testing.T) {
+	var router Router
+	e := router.Endpoint("/posts")
+	n := (*node)(e)
+	e.Methods("POST").Handler(testHandler("post"))
+	list := n.methodList
+	e.Handler(testHandler("default"))
+	e.GET(testHandler("get"))
+	e.Handlers(map[string]http.Handler{"delete": testHandler("delete")})
+	if expected := []string{"DELETE", "GET", "POST"}; !reflect.DeepEqual(n.methodList, expected) {
+		t.Errorf("Expected method list %v, got %v", expected, n.methodList)
+	}
+	if !reflect.DeepEqual(list, []string{"POST"}) {
+		t.Errorf("Expected the old method list not to be modified, got %v", list)
+	}
+	if m := router.Match("PUT", "/posts"); !reflect.DeepEqual(m.Methods, n.methodList) {
+		t.Errorf("Expected Match to report %v, got %v", n.methodList, m.Methods)
+	}
+}
+
+func TestMethodListNotShared(t *testing.T) {
+	var router Router
+	e := router.Endpoint("/posts")
+	e.Methods("GET", "POST").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header[http.CanonicalHeaderKey("Trout-Methods")][0] = "PATCH"
+	})
+	m := router.Match("PUT", "/posts")
+	m.Methods[0] = "DELETE"
+	r, err := http.NewRequest("GET", "/posts", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	expected := []string{"GET", "POST"}
+	if n := (*node)(e); !reflect.DeepEqual(n.methodList, expected) {
+		t.Errorf("Expected method list %v, got %v", expected, n.methodList)
+	}
+	if m := router.Match("PUT", "/posts"); !reflect.DeepEqual(m.Methods, expected) {
+		t.Errorf("Expected Match to report %v, got %v", expected, m.Methods)
+	}
+}
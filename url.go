@@ -0,0 +1,241 @@
+package trout
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URL returns the path for `e`, with each parameter filled in with the value
+// for it in `params`. Each value is escaped so that it fills a single path
+// element, except for catch-all parameters, which may contain `/` to fill
+// multiple path elements. An error is returned if `params` is missing a value
+// for any parameter, or if a value doesn't satisfy the parameter's
+// constraint.
+//
+// If a parameter name is used more than once in the URL template, the same
+// value is used for each instance. Use URLValues to set a different value for
+// each instance.
+//
+// The returned path will end in a `/` if the Endpoint was defined with one. It
+// does not include any prefix set on the Router using SetPrefix.
+func (e *Endpoint) URL(params map[string]string) (string, error) {
+	return buildURL((*node)(e), singleParams(params))
+}
+
+// URLValues returns the path for `e`, just like URL, except each instance of
+// a parameter name is filled in with the next value for it in `params`, in the
+// order the instances appear in the URL template.
+func (e *Endpoint) URLValues(params map[string][]string) (string, error) {
+	return buildURL((*node)(e), multiParams(params))
+}
+
+// URL returns the path for `p`, with each parameter filled in with the value
+// for it in `params`, following the same rules as Endpoint.URL.
+func (p *Prefix) URL(params map[string]string) (string, error) {
+	return buildURL((*node)(p), singleParams(params))
+}
+
+// URLValues returns the path for `p`, with each instance of a parameter name
+// filled in with the next value for it in `params`, following the same rules
+// as Endpoint.URLValues.
+func (p *Prefix) URLValues(params map[string][]string) (string, error) {
+	return buildURL((*node)(p), multiParams(params))
+}
+
+// URLQuery returns the URL for `e`, just like URL, followed by a query string
+// that sets each parameter in `query`. If `e` was created using
+// Endpoint.Query, the query parameters it requires are included too, unless
+// `query` sets them. The query string is left off if there are no parameters
+// to set.
+func (e *Endpoint) URLQuery(params map[string]string, query url.Values) (string, error) {
+	n := (*node)(e)
+	path, err := buildURL(n, singleParams(params))
+	if err != nil {
+		return "", err
+	}
+	return withQuery(path, n, query), nil
+}
+
+// URLQuery returns the URL for `p`, just like URL, followed by a query string
+// that sets each parameter in `query`, following the same rules as
+// Endpoint.URLQuery.
+func (p *Prefix) URLQuery(params map[string]string, query url.Values) (string, error) {
+	n := (*node)(p)
+	path, err := buildURL(n, singleParams(params))
+	if err != nil {
+		return "", err
+	}
+	return withQuery(path, n, query), nil
+}
+
+// withQuery returns `path` followed by a query string setting the parameters
+// in `query`, and any query parameters the terminator `n` requires that
+// `query` doesn't set.
+func withQuery(path string, n *node, query url.Values) string {
+	values := make(url.Values, len(query)+len(n.query))
+	for k, v := range query {
+		values[k] = v
+	}
+	for _, q := range n.query {
+		if _, ok := query[q.key]; !ok {
+			values[q.key] = append(values[q.key], q.value)
+		}
+	}
+	if len(values) < 1 {
+		return path
+	}
+	return path + "?" + values.Encode()
+}
+
+// singleParams returns a function that returns the value for a parameter
+// from `params`.
+func singleParams(params map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		val, ok := params[name]
+		return val, ok
+	}
+}
+
+// multiParams returns a function that returns successive values for a
+// parameter from `params` each time it's called.
+func multiParams(params map[string][]string) func(string) (string, bool) {
+	used := map[string]int{}
+	return func(name string) (string, bool) {
+		vals := params[name]
+		if used[name] >= len(vals) {
+			return "", false
+		}
+		val := vals[used[name]]
+		used[name]++
+		return val, true
+	}
+}
+
+// buildURL returns the path to `n`, using `param` to retrieve the value for
+// each dynamic key along the way.
+func buildURL(n *node, param func(string) (string, bool)) (string, error) {
+	trailingSlash := n.trailingSlash
+	var nodes []*node
+	for ; n != nil && n.parent != nil; n = n.parent {
+		if n.value.nul {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	var b strings.Builder
+	for i := len(nodes) - 1; i >= 0; i-- {
+		k := nodes[i].value
+		if !k.dynamic {
+			for _, segment := range nodes[i].allSegments() {
+				if segment != "" {
+					b.WriteString("/" + url.PathEscape(segment))
+				}
+			}
+			continue
+		}
+		val, ok := param(k.value)
+		if !ok {
+			return "", fmt.Errorf("trout: missing value for parameter %q", k.value)
+		}
+		if !k.allows(val) {
+			return "", fmt.Errorf("trout: value %q for parameter %q doesn't match constraint %q", val, k.value, k.constraint)
+		}
+		if !k.catchAll {
+			b.WriteString("/" + url.PathEscape(k.before+val+k.after))
+			continue
+		}
+		for _, piece := range strings.Split(strings.Trim(val, "/"), "/") {
+			b.WriteString("/" + url.PathEscape(piece))
+		}
+	}
+	if b.Len() < 1 {
+		return "/", nil
+	}
+	if trailingSlash {
+		b.WriteString("/")
+	}
+	return b.String(), nil
+}
+
+// Name sets a name for `e` that can be passed to Router.URLFor to build URLs
+// for `e`. Names must be unique within a Router; Name panics if another
+// Endpoint or Prefix on the same Router already has the name. Use SetName to
+// get an error instead. Calling Name again replaces the name `e` was given
+// before.
+//
+// Name is not concurrency-safe, and should not be used while the Router `e`
+// belongs to is actively routing traffic.
+func (e *Endpoint) Name(name string) *Endpoint {
+	if err := e.SetName(name); err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// SetName sets a name for `e`, exactly like Name, but returns an error
+// instead of panicking if another Endpoint or Prefix on the same Router
+// already has the name. The name `e` had before is kept if there's an error.
+//
+// SetName is not concurrency-safe, and should not be used while the Router
+// `e` belongs to is actively routing traffic.
+func (e *Endpoint) SetName(name string) error {
+	return (*node)(e).setName(name)
+}
+
+// Name sets a name for `p` that can be passed to Router.URLFor to build URLs
+// for `p`, following the same rules as Endpoint.Name.
+func (p *Prefix) Name(name string) *Prefix {
+	if err := p.SetName(name); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// SetName sets a name for `p`, exactly like Name, but returns an error
+// instead of panicking if another Endpoint or Prefix on the same Router
+// already has the name.
+func (p *Prefix) SetName(name string) error {
+	return (*node)(p).setName(name)
+}
+
+// URLFor returns the path for the Endpoint or Prefix that was given `name`
+// using its Name method. `params` should be pairs of parameter names and
+// values, like URLFor("comment", "slug", "foo", "id", "bar"). If a parameter
+// name is used more than once in the URL template, it can either be passed
+// once, to use the same value for each instance, or once per instance, in
+// the order they appear in the template.
+//
+// An error is returned if no route has been given `name`, if `params` doesn't
+// have an even number of elements, or for any of the reasons Endpoint.URL
+// returns an error. Unlike Endpoint.URL, the returned path includes any prefix
+// set with SetPrefix.
+func (router Router) URLFor(name string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("trout: odd number of parameters for route %q", name)
+	}
+	var n *node
+	if router.trie != nil {
+		router.trie.RLock()
+		n = router.trie.root.names[name]
+		router.trie.RUnlock()
+	}
+	if n == nil {
+		return "", fmt.Errorf("trout: no route named %q", name)
+	}
+	values := map[string][]string{}
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = append(values[params[i]], params[i+1])
+	}
+	multi := multiParams(values)
+	res, err := buildURL(n, func(name string) (string, bool) {
+		if vals := values[name]; len(vals) == 1 {
+			return vals[0], true
+		}
+		return multi(name)
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(router.prefix, "/") + res, nil
+}
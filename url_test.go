@@ -0,0 +1,174 @@
+package trout
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	type testCase struct {
+		template string
+		prefix   bool
+		params   map[string]string
+		expected string
+		err      bool
+	}
+	cases := []testCase{
+		{"/", false, nil, "/", false},
+		{"/posts/{slug}", false, map[string]string{"slug": "hello-world"}, "/posts/hello-world", false},
+		{"/posts/{slug}", false, map[string]string{"slug": "a b/c"}, "/posts/a%20b%2Fc", false},
+		{"/posts/{slug}/comments/{id:int}", false, map[string]string{"slug": "foo", "id": "12"}, "/posts/foo/comments/12", false},
+		{"/posts/{slug}/comments/{id:int}", false, map[string]string{"slug": "foo", "id": "bar"}, "", true},
+		{"/posts/{slug}/comments/{id}", false, map[string]string{"slug": "foo"}, "", true},
+		{"/files/{path...}", false, map[string]string{"path": "css/site main.css"}, "/files/css/site%20main.css", false},
+		{"/users/{id}", true, map[string]string{"id": "paddy"}, "/users/paddy", false},
+		{"/dirs/{id}/", false, map[string]string{"id": "foo"}, "/dirs/foo/", false},
+		{"/{id}/x/{id}", false, map[string]string{"id": "same"}, "/same/x/same", false},
+	}
+	for _, c := range cases {
+		var router Router
+		var res string
+		var err error
+		if c.prefix {
+			res, err = router.Prefix(c.template).URL(c.params)
+		} else {
+			res, err = router.Endpoint(c.template).URL(c.params)
+		}
+		if c.err && err == nil {
+			t.Errorf("Expected an error building %s with %v, got %q", c.template, c.params, res)
+			continue
+		}
+		if !c.err && err != nil {
+			t.Errorf("Unexpected error building %s with %v: %+v", c.template, c.params, err)
+			continue
+		}
+		if res != c.expected {
+			t.Errorf("Expected %s with %v to build %q, got %q", c.template, c.params, c.expected, res)
+		}
+	}
+}
+
+func TestURLQuery(t *testing.T) {
+	var router Router
+	posts := router.Endpoint("/posts/{slug}")
+	drafts := posts.Query("draft", "").Query("v", "2").GET(testHandler("drafts"))
+
+	type testCase struct {
+		e        *Endpoint
+		query    url.Values
+		expected string
+	}
+	cases := []testCase{
+		{posts, nil, "/posts/foo"},
+		{posts, url.Values{"page": {"2"}, "q": {"a b"}}, "/posts/foo?page=2&q=a+b"},
+		{drafts, nil, "/posts/foo?draft=&v=2"},
+		{drafts, url.Values{"v": {"3"}, "page": {"1"}}, "/posts/foo?draft=&page=1&v=3"},
+	}
+	for _, c := range cases {
+		res, err := c.e.URLQuery(map[string]string{"slug": "foo"}, c.query)
+		if err != nil {
+			t.Errorf("Unexpected error building URL with %v: %+v", c.query, err)
+			continue
+		}
+		if res != c.expected {
+			t.Errorf("Expected %v to build %q, got %q", c.query, c.expected, res)
+		}
+	}
+	if u, _ := drafts.URLQuery(map[string]string{"slug": "foo"}, nil); router.Match("GET", u).Pattern != "/posts/{slug}" {
+		t.Errorf("Expected %q to match the Endpoint it was built for", u)
+	}
+	if _, err := posts.URLQuery(nil, url.Values{"page": {"2"}}); err == nil {
+		t.Errorf("Expected an error building a URL without its parameters")
+	}
+	if res, err := router.Prefix("/static").URLQuery(nil, url.Values{"v": {"1"}}); err != nil || res != "/static?v=1" {
+		t.Errorf("Expected the Prefix URL to be %q, got %q (%v)", "/static?v=1", res, err)
+	}
+}
+
+func TestURLValues(t *testing.T) {
+	var router Router
+	e := router.Endpoint("/posts/{id}/comments/{id}")
+	res, err := e.URLValues(map[string][]string{"id": {"foo", "bar"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if res != "/posts/foo/comments/bar" {
+		t.Errorf("Expected %q, got %q", "/posts/foo/comments/bar", res)
+	}
+	_, err = e.URLValues(map[string][]string{"id": {"foo"}})
+	if err == nil {
+		t.Error("Expected an error when a repeated parameter is missing a value, got nil")
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	type testCase struct {
+		name     string
+		params   []string
+		expected string
+		err      bool
+	}
+	cases := []testCase{
+		{"post", []string{"slug", "foo"}, "/api/posts/foo", false},
+		{"comment", []string{"slug", "foo", "id", "12"}, "/api/posts/foo/comments/12", false},
+		{"repeated", []string{"id", "foo"}, "/api/foo/x/foo", false},
+		{"repeated", []string{"id", "foo", "id", "bar"}, "/api/foo/x/bar", false},
+		{"static", []string{"dir", "css"}, "/api/static/css", false},
+		{"post", []string{"slug"}, "", true},
+		{"post", nil, "", true},
+		{"missing", nil, "", true},
+	}
+	var router Router
+	router.SetPrefix("/api/")
+	router.Endpoint("/posts/{slug}").Name("post")
+	router.Endpoint("/posts/{slug}/comments/{id}").Name("comment")
+	router.Endpoint("/{id}/x/{id}").Name("repeated")
+	router.Prefix("/static/{dir}").Name("static")
+	for _, c := range cases {
+		res, err := router.URLFor(c.name, c.params...)
+		if c.err && err == nil {
+			t.Errorf("Expected an error building %s with %v, got %q", c.name, c.params, res)
+			continue
+		}
+		if !c.err && err != nil {
+			t.Errorf("Unexpected error building %s with %v: %+v", c.name, c.params, err)
+			continue
+		}
+		if res != c.expected {
+			t.Errorf("Expected %s with %v to build %q, got %q", c.name, c.params, c.expected, res)
+		}
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	var router Router
+	router.Endpoint("/posts").Name("posts")
+	// naming the same route again is fine
+	router.Endpoint("/posts").Name("posts")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Name to panic when the name is already used")
+		}
+	}()
+	router.Endpoint("/users").Name("posts")
+}
+
+func TestSetName(t *testing.T) {
+	var router Router
+	if err := router.Endpoint("/posts").SetName("posts"); err != nil {
+		t.Fatalf("Unexpected error naming /posts: %+v", err)
+	}
+	users := router.Endpoint("/users").Name("users")
+	if err := users.SetName("posts"); err == nil {
+		t.Errorf("Expected an error when the name is already used")
+	}
+	if err := router.Prefix("/static").SetName("posts"); err == nil {
+		t.Errorf("Expected an error when a Prefix uses a name that's already used")
+	}
+	if u, err := router.URLFor("users"); err != nil || u != "/users" {
+		t.Errorf("Expected /users to keep its name, got %q, %v", u, err)
+	}
+	if u, err := router.URLFor("posts"); err != nil || u != "/posts" {
+		t.Errorf("Expected posts to still name /posts, got %q, %v", u, err)
+	}
+}
@@ -0,0 +1,198 @@
+package trout
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ConflictKind describes why a route was reported as a Conflict.
+type ConflictKind int
+
+const (
+	// ConflictDuplicate means the route has the same path elements as
+	// another route, differing only in parameter names, and both have
+	// handlers for some of the same methods. The route that was defined
+	// first will always win for those methods.
+	ConflictDuplicate ConflictKind = iota
+	// ConflictRepeatedParam means the route uses the same parameter
+	// name more than once. This is allowed, and every value is kept, but
+	// http.Header's Get method will only ever return the first one,
+	// which is usually a mistake.
+	ConflictRepeatedParam
+)
+
+// String returns a human-readable description of `k`.
+func (k ConflictKind) String() string {
+	switch k {
+	case ConflictDuplicate:
+		return "duplicate"
+	case ConflictRepeatedParam:
+		return "repeated parameter"
+	}
+	return "unknown"
+}
+
+// Conflict describes a route that can never be used to serve some or all of
+// the requests it matches, because another route will always win.
+type Conflict struct {
+	// Kind describes why the route can't be used.
+	Kind ConflictKind
+	// Pattern is the URL template of the route that can't be used, as it
+	// would be set in the Trout-Pattern header.
+	Pattern string
+	// ShadowedBy is the URL template of the route that will be used
+	// instead.
+	ShadowedBy string
+	// Methods holds the methods the route can't be used for, sorted. A
+	// default handler set using the Handler method is listed as "*".
+	Methods []string
+	// Param is the name of the parameter that was repeated, for
+	// ConflictRepeatedParam. ShadowedBy is the same as Pattern, as the
+	// first use of the parameter shadows the others.
+	Param string
+}
+
+// String returns a human-readable description of `c`.
+func (c Conflict) String() string {
+	if c.Kind == ConflictRepeatedParam {
+		return fmt.Sprintf("%s: %s [%s] uses the parameter %q more than once", c.Kind, c.Pattern, strings.Join(c.Methods, ", "), c.Param)
+	}
+	return fmt.Sprintf("%s: %s [%s] is shadowed by %s", c.Kind, c.Pattern, strings.Join(c.Methods, ", "), c.ShadowedBy)
+}
+
+// Validate walks every Endpoint and Prefix on `router`, and returns a
+// Conflict for each one that can never be used for some or all of the
+// requests it matches. This happens when two routes differ only in the names
+// of their parameters; the one reported as shadowed is the one that loses to
+// the other when routing, taking Priority into account. Routes that use the
+// same parameter name more than once are also reported, as http.Header's Get
+// method can only return the first value. A Router with no conflicts returns
+// nil. Conflicts are returned in the same stable order as Routes.
+//
+// Validate is meant to be used in tests or at startup, to catch routes that
+// were accidentally shadowed.
+func (router Router) Validate() []Conflict {
+	if router.trie == nil {
+		return nil
+	}
+	router.trie.RLock()
+	defer router.trie.RUnlock()
+
+	pattern := func(n *node) string {
+		return pattern(router.prefix, n)
+	}
+
+	var conflicts []Conflict
+	shapes := map[string]*node{}
+	walk := func(n *node) {
+		if param := repeatedParam(n); param != "" {
+			conflicts = append(conflicts, Conflict{
+				Kind:       ConflictRepeatedParam,
+				Pattern:    pattern(n),
+				ShadowedBy: pattern(n),
+				Methods:    sortedMethodKeys(n, nil),
+				Param:      param,
+			})
+		}
+		shape := n.root().host + nodeShape(n) + "?" + queryString(n)
+		winner, ok := shapes[shape]
+		if !ok {
+			shapes[shape] = n
+			return
+		}
+		shadowed := n
+		if beats(n, winner) {
+			shapes[shape] = n
+			shadowed, winner = winner, n
+		}
+		if methods := sortedMethodKeys(shadowed, winner); len(methods) > 0 {
+			conflicts = append(conflicts, Conflict{
+				Kind:       ConflictDuplicate,
+				Pattern:    pattern(shadowed),
+				ShadowedBy: pattern(winner),
+				Methods:    methods,
+			})
+		}
+	}
+	for _, root := range router.trie.roots() {
+		walkTerminators(root, walk)
+	}
+	return conflicts
+}
+
+// beats returns true if pickNode would pick the terminator `n` over the
+// terminator `other`, which was defined before it, for a method both have
+// handlers for: when `n` has a higher priority, or the same priority and a
+// higher score.
+func beats(n, other *node) bool {
+	if n.priority != other.priority {
+		return n.priority > other.priority
+	}
+	return compareScores(n.parent, other.parent) > 0
+}
+
+// repeatedParam returns the name of the first parameter that's used more than
+// once in the path leading to the terminator `n`, or an empty string if none
+// is. Names are compared the same way RequestVars compares them.
+func repeatedParam(n *node) string {
+	var repeated string
+	seen := map[string]bool{}
+	for ; n != nil; n = n.parent {
+		if !n.value.dynamic {
+			continue
+		}
+		name := http.CanonicalHeaderKey(n.value.value)
+		if seen[name] {
+			repeated = n.value.value
+		}
+		seen[name] = true
+	}
+	return repeated
+}
+
+// nodeShape returns a string describing the path elements leading to the
+// terminator `n`, ignoring the names of parameters, so that routes that will
+// match exactly the same requests have the same shape.
+func nodeShape(n *node) string {
+	var res string
+	for ; n != nil && n.parent != nil; n = n.parent {
+		if n.value.nul {
+			continue
+		}
+		k := n.value
+		shape := "/" + braceEscaper.Replace(k.value)
+		if k.dynamic {
+			shape = "/{:" + k.constraint + "}"
+			if k.catchAll {
+				shape = "/{...:" + k.constraint + "}"
+			}
+			if k.mixed() {
+				shape = "/" + braceEscaper.Replace(k.before) + shape[1:] + braceEscaper.Replace(k.after)
+			}
+		}
+		if k.prefix {
+			shape += "::prefix"
+		}
+		res = shape + res
+	}
+	return res
+}
+
+// sortedMethodKeys returns the sorted keys of the methods of `n`, including
+// the catch-all method, that `other` also has. If `other` is nil, all the
+// keys are returned.
+func sortedMethodKeys(n, other *node) []string {
+	var methods []string
+	for method := range n.methods {
+		if other != nil {
+			if _, ok := other.methods[method]; !ok {
+				continue
+			}
+		}
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,73 @@
+package trout
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	var router Router
+	router.Endpoint("/{id}").Methods("GET", "PUT").Handler(testHandler("id"))
+	router.Endpoint("/{name}").Methods("GET", "POST").Handler(testHandler("name"))
+	router.Endpoint("/v1").Methods("GET").Handler(testHandler("v1"))
+	router.Endpoint("/users/{id:int}").Handler(testHandler("user-id"))
+	router.Endpoint("/users/{name}").Handler(testHandler("user-name"))
+	router.Endpoint("/posts/{slug}").Handler(testHandler("post"))
+	router.Endpoint("/posts/{id}").Methods("DELETE").Handler(testHandler("post-delete"))
+	router.Prefix("/static").Handler(testHandler("static"))
+	router.Endpoint("/static/logo.png").Methods("GET").Handler(testHandler("logo"))
+	router.Endpoint("/v1").Query("beta", "").Methods("GET").Handler(testHandler("v1-beta"))
+	router.Endpoint("/{id}").Query("format", "json").Methods("GET").Handler(testHandler("id-json"))
+	router.Endpoint("/{name}").Query("format", "json").Methods("GET", "POST").Handler(testHandler("name-json"))
+
+	expected := []Conflict{
+		{Kind: ConflictDuplicate, Pattern: "/{name}", ShadowedBy: "/{id}", Methods: []string{"GET"}},
+		{Kind: ConflictDuplicate, Pattern: "/{name}", ShadowedBy: "/{id}", Methods: []string{"GET"}},
+	}
+	conflicts := router.Validate()
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected conflicts to be %+v, got %+v", expected, conflicts)
+	}
+
+	var clean Router
+	clean.Endpoint("/{id}").Handler(testHandler("id"))
+	clean.Endpoint("/v1").Handler(testHandler("v1"))
+	if conflicts := clean.Validate(); conflicts != nil {
+		t.Errorf("Expected no conflicts, got %+v", conflicts)
+	}
+}
+
+func TestValidatePriority(t *testing.T) {
+	var router Router
+	router.Endpoint("/{id}").GET(testHandler("id"))
+	router.Endpoint("/{name}").Priority(5).GET(testHandler("name"))
+	router.Endpoint("/{slug}").Methods("GET", "POST").Handler(testHandler("slug"))
+
+	expected := []Conflict{
+		{Kind: ConflictDuplicate, Pattern: "/{id}", ShadowedBy: "/{name}", Methods: []string{"GET"}},
+		{Kind: ConflictDuplicate, Pattern: "/{slug}", ShadowedBy: "/{name}", Methods: []string{"GET"}},
+	}
+	conflicts := router.Validate()
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected conflicts to be %+v, got %+v", expected, conflicts)
+	}
+	if m := router.Match("GET", "/foo"); m.Pattern != conflicts[0].ShadowedBy {
+		t.Errorf("Expected %s to be served, got %s", conflicts[0].ShadowedBy, m.Pattern)
+	}
+}
+
+func TestValidateRepeatedParam(t *testing.T) {
+	var router Router
+	router.Endpoint("/posts/{id}/comments/{ID}").Methods("GET").Handler(testHandler("comment"))
+	router.Endpoint("/posts/{id}/comments").Handler(testHandler("comments"))
+	expected := []Conflict{
+		{Kind: ConflictRepeatedParam, Pattern: "/posts/{id}/comments/{ID}", ShadowedBy: "/posts/{id}/comments/{ID}", Methods: []string{"GET"}, Param: "id"},
+	}
+	conflicts := router.Validate()
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected conflicts to be %+v, got %+v", expected, conflicts)
+	}
+	if s := conflicts[0].String(); s != `repeated parameter: /posts/{id}/comments/{ID} [GET] uses the parameter "id" more than once` {
+		t.Errorf("Unexpected string for conflict: %q", s)
+	}
+}
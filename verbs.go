@@ -0,0 +1,98 @@
+package trout
+
+import "net/http"
+
+// GET sets `h` as the http.Handler for GET requests that `e` matches, and
+// returns `e` so more methods can be chained. It's a shorthand for
+// `e.Methods("GET").Handler(h)`.
+//
+// GET is not concurrency-safe, and should not be used while the Router `e`
+// belongs to is actively routing traffic.
+func (e *Endpoint) GET(h http.Handler) *Endpoint {
+	e.Methods(http.MethodGet).Handler(h)
+	return e
+}
+
+// POST sets `h` as the http.Handler for POST requests that `e` matches, and
+// returns `e`, following the same rules as Endpoint.GET.
+func (e *Endpoint) POST(h http.Handler) *Endpoint {
+	e.Methods(http.MethodPost).Handler(h)
+	return e
+}
+
+// PUT sets `h` as the http.Handler for PUT requests that `e` matches, and
+// returns `e`, following the same rules as Endpoint.GET.
+func (e *Endpoint) PUT(h http.Handler) *Endpoint {
+	e.Methods(http.MethodPut).Handler(h)
+	return e
+}
+
+// DELETE sets `h` as the http.Handler for DELETE requests that `e` matches, and
+// returns `e`, following the same rules as Endpoint.GET.
+func (e *Endpoint) DELETE(h http.Handler) *Endpoint {
+	e.Methods(http.MethodDelete).Handler(h)
+	return e
+}
+
+// PATCH sets `h` as the http.Handler for PATCH requests that `e` matches, and
+// returns `e`, following the same rules as Endpoint.GET.
+func (e *Endpoint) PATCH(h http.Handler) *Endpoint {
+	e.Methods(http.MethodPatch).Handler(h)
+	return e
+}
+
+// Any sets `h` as the http.Handler for requests that `e` matches using any
+// method, and returns `e`, following the same rules as Endpoint.GET. It's a
+// shorthand for `e.Methods("*").Handler(h)`, and does the same thing as
+// Endpoint.Handler: handlers set for specific methods are still used for
+// requests using those methods, and `h` is used for all other requests.
+func (e *Endpoint) Any(h http.Handler) *Endpoint {
+	e.Methods(catchAllMethod).Handler(h)
+	return e
+}
+
+// GET sets `h` as the http.Handler for GET requests that `p` matches, and
+// returns `p` so more methods can be chained. It's a shorthand for
+// `p.Methods("GET").Handler(h)`.
+//
+// GET is not concurrency-safe, and should not be used while the Router `p`
+// belongs to is actively routing traffic.
+func (p *Prefix) GET(h http.Handler) *Prefix {
+	p.Methods(http.MethodGet).Handler(h)
+	return p
+}
+
+// POST sets `h` as the http.Handler for POST requests that `p` matches, and
+// returns `p`, following the same rules as Prefix.GET.
+func (p *Prefix) POST(h http.Handler) *Prefix {
+	p.Methods(http.MethodPost).Handler(h)
+	return p
+}
+
+// PUT sets `h` as the http.Handler for PUT requests that `p` matches, and
+// returns `p`, following the same rules as Prefix.GET.
+func (p *Prefix) PUT(h http.Handler) *Prefix {
+	p.Methods(http.MethodPut).Handler(h)
+	return p
+}
+
+// DELETE sets `h` as the http.Handler for DELETE requests that `p` matches, and
+// returns `p`, following the same rules as Prefix.GET.
+func (p *Prefix) DELETE(h http.Handler) *Prefix {
+	p.Methods(http.MethodDelete).Handler(h)
+	return p
+}
+
+// PATCH sets `h` as the http.Handler for PATCH requests that `p` matches, and
+// returns `p`, following the same rules as Prefix.GET.
+func (p *Prefix) PATCH(h http.Handler) *Prefix {
+	p.Methods(http.MethodPatch).Handler(h)
+	return p
+}
+
+// Any sets `h` as the http.Handler for requests that `p` matches using any
+// method, and returns `p`, following the same rules as Endpoint.Any.
+func (p *Prefix) Any(h http.Handler) *Prefix {
+	p.Methods(catchAllMethod).Handler(h)
+	return p
+}
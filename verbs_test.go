@@ -0,0 +1,150 @@
+package trout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerbShortcuts(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "get"},
+		{"POST", "/posts", "post"},
+		{"PUT", "/posts", "put"},
+		{"DELETE", "/posts", "delete"},
+		{"PATCH", "/posts", "patch"},
+		{"OPTIONS", "/posts", "405"},
+		{"GET", "/static/css/site.css", "static-get"},
+		{"DELETE", "/static/css/site.css", "static-delete"},
+		{"POST", "/static/css/site.css", "405"},
+	}
+	var router Router
+	router.Handle405 = testHandler("405")
+	router.Endpoint("/posts").
+		GET(testHandler("get")).
+		POST(testHandler("post")).
+		PUT(testHandler("put")).
+		DELETE(testHandler("delete")).
+		PATCH(testHandler("patch"))
+	router.Prefix("/static").
+		GET(testHandler("static-get")).
+		DELETE(testHandler("static-delete"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "get"},
+		{"POST", "/posts", "post"},
+		{"DELETE", "/posts", "default"},
+		{"GET", "/static/site.css", "static-get"},
+		{"POST", "/static/site.css", "405"},
+	}
+	var router Router
+	router.Handle405 = testHandler("405")
+	router.Endpoint("/posts").Handlers(map[string]http.Handler{
+		"get":  testHandler("get"),
+		"POST": testHandler("post"),
+		"*":    testHandler("default"),
+	})
+	router.Prefix("/static").Handlers(map[string]http.Handler{
+		"GET": testHandler("static-get"),
+	})
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestAny(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "get"},
+		{"POST", "/posts", "any"},
+		{"PROPFIND", "/posts", "any"},
+		{"GET", "/static/site.css", "static-any"},
+		{"GET", "/users", "users-any"},
+		{"PUT", "/users", "users-any"},
+	}
+	var router Router
+	router.Endpoint("/posts").GET(testHandler("get")).Any(testHandler("any"))
+	router.Prefix("/static").Any(testHandler("static-any"))
+	router.Endpoint("/users").Methods("*").Handler(testHandler("users-any"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+}
+
+func TestMethodsExcept(t *testing.T) {
+	type testCase struct {
+		method, url, handler string
+	}
+	cases := []testCase{
+		{"GET", "/posts", "posts"},
+		{"PROPFIND", "/posts", "posts"},
+		{"DELETE", "/posts", "405"},
+		{"PUT", "/posts", "405"},
+		{"GET", "/static/site.css", "static"},
+		{"POST", "/static/site.css", "static-post"},
+		{"PATCH", "/static/site.css", "405"},
+	}
+	var router Router
+	router.Handle405 = testHandler("405")
+	router.Endpoint("/posts").MethodsExcept("delete", "PUT").Handler(testHandler("posts"))
+	router.Prefix("/static").MethodsExcept("POST", "PATCH").Handler(testHandler("static"))
+	router.Prefix("/static").POST(testHandler("static-post"))
+	for _, c := range cases {
+		r, err := http.NewRequest(c.method, c.url, nil)
+		if err != nil {
+			t.Fatalf("Error creating request for %s %s: %+v", c.method, c.url, err)
+		}
+		h, _ := router.getHandler(r)
+		if res := string(h.(testHandler)); res != c.handler {
+			t.Errorf("Expected to route \"%s %s\" to %s, routed to %s", c.method, c.url, c.handler, res)
+		}
+	}
+
+	router.Handle405 = nil
+	r, err := http.NewRequest("DELETE", "/posts", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %+v", err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected a 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "CONNECT, GET, HEAD, OPTIONS, PATCH, POST, TRACE" {
+		t.Errorf("Expected the Allow header to leave out the excluded methods, got %q", allow)
+	}
+}